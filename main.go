@@ -7,11 +7,15 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/pandorasnox/kubernetes-default-container-resources/pkg"
 	"github.com/sirupsen/logrus"
 	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/dynamic"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
 )
 
 func main() {
@@ -20,10 +24,23 @@ func main() {
 	limitCPU := flag.String("limitCPU", "0.5", "cpu limit (default 0.5 cores)")
 	requestMemory := flag.String("requestMemory", "512M", "memory request (default 1G)")
 	requestCPU := flag.String("requestCPU", "0.05", "cpu request (default 0.1 cores)")
+	initLimitMemory := flag.String("initLimitMemory", "512M", "init container memory limit")
+	initLimitCPU := flag.String("initLimitCPU", "0.5", "init container cpu limit")
+	initRequestMemory := flag.String("initRequestMemory", "256M", "init container memory request")
+	initRequestCPU := flag.String("initRequestCPU", "0.05", "init container cpu request")
 	addr := flag.String("addr", ":8083", "address to bind to")
 	sslCert := flag.String("sslCert", "/certs/ssl-cert.pem", "address to bind to")
 	sslKey := flag.String("sslKey", "/certs/ssl-key.pem", "address to bind to")
 	dryRun := flag.Bool("dry-run", false, "enables dry-run mode, always returning success AdmissionReview")
+	strategyName := flag.String("strategy", "complementToDefault", fmt.Sprintf("patch strategy, one of %v", webhook.StrategyNames()))
+	configFile := flag.String("config", "", "path to the YAML/JSON config file (optional)")
+	namespaceOverrides := flag.Bool("namespaceOverrides", false, "override the container defaults by annotations of the pods namespace (needs in-cluster access to namespaces)")
+	derive := flag.Bool("derive", false, "derive missing limits from the containers requests (and missing requests from its limits) before applying the strategy")
+	configReloadInterval := flag.Duration("configReloadInterval", 10*time.Second, "interval to check the -config file for changes, 0 disables the reload")
+	policies := flag.Bool("policies", false, "watch the ClusterResourceDefaultPolicy and ResourceDefaultPolicy custom resources (needs in-cluster access to them)")
+	guaranteed := flag.Bool("guaranteed", false, "set the cpu/memory requests of containers no rule matches to their limits, denying pods which can't get Guaranteed QoS (can't be combined with a default in the config)")
+	optIn := flag.Bool("optIn", false, "only default pods in namespaces labeled default-resources-webhook: <profile>, with that profile of the config file (needs in-cluster access to namespaces)")
+	validateConfig := flag.Bool("validateConfig", false, "only validate the config file given by -config and exit")
 	flag.Parse()
 
 	logrus.SetFormatter(&logrus.JSONFormatter{
@@ -36,29 +53,140 @@ func main() {
 	}).Info("dry-run status")
 
 	logrus.WithFields(logrus.Fields{
-		"tlsDisabled":   *tlsDisabled,
-		"addr":          *addr,
-		"limitMemory":   *limitMemory,
-		"limitCPU":      *limitCPU,
-		"requestMemory": *requestMemory,
-		"requestCPU":    *requestCPU,
+		"tlsDisabled":        *tlsDisabled,
+		"addr":               *addr,
+		"limitMemory":        *limitMemory,
+		"limitCPU":           *limitCPU,
+		"requestMemory":      *requestMemory,
+		"requestCPU":         *requestCPU,
+		"initLimitMemory":    *initLimitMemory,
+		"initLimitCPU":       *initLimitCPU,
+		"initRequestMemory":  *initRequestMemory,
+		"initRequestCPU":     *initRequestCPU,
+		"strategy":           *strategyName,
+		"derive":             *derive,
+		"guaranteed":         *guaranteed,
+		"config":             *configFile,
+		"namespaceOverrides": *namespaceOverrides,
+		"configReload":       configReloadInterval.String(),
+		"policies":           *policies,
+		"optIn":              *optIn,
+		"validateConfig":     *validateConfig,
 	}).Info("programm flags")
 
+	strategy, err := webhook.StrategyByName(*strategyName)
+	if err != nil {
+		log.Fatalf("could not select patch strategy based on program flags: %s", err)
+	}
+	if *derive {
+		strategy = webhook.Derive{Strategy: strategy}
+	}
+
+	if *validateConfig && *configFile == "" {
+		log.Fatalf("-validateConfig needs the config file given by -config")
+	}
+
+	config := webhook.Config{}
+	if *configFile != "" {
+		config, err = webhook.LoadConfig(*configFile)
+		if err != nil {
+			log.Fatalf("could not load config: %s", err)
+		}
+	}
+	if *guaranteed && config.Default != nil {
+		log.Fatalf("-guaranteed has no effect, since the default of config %s replaces the flag defaults: set qos: Guaranteed in it instead", *configFile)
+	}
+	if *validateConfig {
+		fmt.Printf("config %s is valid\n", *configFile)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"config": config,
+	}).Info("loaded config")
+
 	defaultResourceRequirements, err := parseResourceRequirements(*limitMemory, *limitCPU, *requestMemory, *requestCPU)
 	if err != nil {
 		log.Fatalf("could not parse resource requirements based on program flags: %s", err)
 	}
 
+	initResourceRequirements, err := parseResourceRequirements(*initLimitMemory, *initLimitCPU, *initRequestMemory, *initRequestCPU)
+	if err != nil {
+		log.Fatalf("could not parse init container resource requirements based on program flags: %s", err)
+	}
+
 	logrus.WithFields(logrus.Fields{
 		"defaultResourceRequirements": defaultResourceRequirements,
+		"initResourceRequirements":    initResourceRequirements,
 	}).Info("parsed defaultResourceRequirements")
 
+	defaults := webhook.Defaults{
+		Containers:     defaultResourceRequirements,
+		InitContainers: &initResourceRequirements,
+	}
+	if *guaranteed {
+		defaults.QoS = string(v1.PodQOSGuaranteed)
+	}
+
+	mutator := &webhook.Mutator{
+		Policy:       config.Policy(defaults),
+		Strategy:     strategy,
+		PodSpecPaths: config.PodSpecPaths,
+		DryRun:       *dryRun,
+	}
+
+	if *configFile != "" && *configReloadInterval > 0 {
+		mutator.Config, err = webhook.NewConfigWatcher(*configFile, defaults)
+		if err != nil {
+			log.Fatalf("could not watch config: %s", err)
+		}
+		go mutator.Config.Run(*configReloadInterval, make(chan struct{}))
+	}
+
+	var restConfig *rest.Config
+	if *namespaceOverrides || *optIn || *policies {
+		restConfig, err = rest.InClusterConfig()
+		if err != nil {
+			log.Fatalf("could not get in-cluster config: %s", err)
+		}
+	}
+
+	if *namespaceOverrides || *optIn {
+		client, err := corev1client.NewForConfig(restConfig)
+		if err != nil {
+			log.Fatalf("could not create client for namespaces: %s", err)
+		}
+		namespaces, err := webhook.NewNamespaceLister(client, 10*time.Minute, make(chan struct{}))
+		if err != nil {
+			log.Fatalf("could not start namespace cache: %s", err)
+		}
+		if *namespaceOverrides {
+			mutator.Namespaces = namespaces
+		}
+		if *optIn {
+			mutator.ProfileNamespaces = namespaces
+		}
+	}
+
+	if *policies {
+		client, err := dynamic.NewForConfig(restConfig)
+		if err != nil {
+			log.Fatalf("could not create client for policies: %s", err)
+		}
+		stop := make(chan struct{})
+		mutator.Policies, err = webhook.NewPolicyController(client, 10*time.Minute, stop)
+		if err != nil {
+			log.Fatalf("could not start policy cache: %s", err)
+		}
+		go mutator.Policies.Run(30*time.Second, stop)
+	}
+
 	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
 
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		err := webhook.Mutate(w, r, defaultResourceRequirements, *dryRun)
+		err := mutator.Mutate(w, r)
 		if err != nil {
 			//todo: use "Fatalf" instead of "Printf"???
 			log.Printf("mutation failed: %s", err)
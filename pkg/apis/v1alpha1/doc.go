@@ -0,0 +1,4 @@
+// Package v1alpha1 contains the ResourceDefaultPolicy custom resources managing container defaults as kubernetes objects.
+// +k8s:deepcopy-gen=package
+// +groupName=default-resources.io
+package v1alpha1
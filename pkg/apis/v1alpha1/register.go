@@ -0,0 +1,37 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// GroupName is the API group of the policy custom resources.
+const GroupName = "default-resources.io"
+
+// SchemeGroupVersion is the group version of the policy custom resources.
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: "v1alpha1"}
+
+var (
+	// SchemeBuilder registers the policy types.
+	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
+	// AddToScheme adds the policy types to a scheme.
+	AddToScheme = SchemeBuilder.AddToScheme
+)
+
+// The resources of the policy custom resources.
+var (
+	ClusterResourceDefaultPolicies = SchemeGroupVersion.WithResource("clusterresourcedefaultpolicies")
+	ResourceDefaultPolicies        = SchemeGroupVersion.WithResource("resourcedefaultpolicies")
+)
+
+func addKnownTypes(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(SchemeGroupVersion,
+		&ClusterResourceDefaultPolicy{},
+		&ClusterResourceDefaultPolicyList{},
+		&ResourceDefaultPolicy{},
+		&ResourceDefaultPolicyList{},
+	)
+	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
+	return nil
+}
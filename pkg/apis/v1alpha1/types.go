@@ -0,0 +1,114 @@
+package v1alpha1
+
+import (
+	k8s_v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterResourceDefaultPolicy provides container defaults for pods of all namespaces.
+type ClusterResourceDefaultPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ResourceDefaultPolicySpec   `json:"spec"`
+	Status ResourceDefaultPolicyStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterResourceDefaultPolicyList is a list of ClusterResourceDefaultPolicies.
+type ClusterResourceDefaultPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ClusterResourceDefaultPolicy `json:"items"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ResourceDefaultPolicy provides container defaults for pods of its own namespace.
+// It takes precedence over the ClusterResourceDefaultPolicies.
+type ResourceDefaultPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ResourceDefaultPolicySpec   `json:"spec"`
+	Status ResourceDefaultPolicyStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ResourceDefaultPolicyList is a list of ResourceDefaultPolicies.
+type ResourceDefaultPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ResourceDefaultPolicy `json:"items"`
+}
+
+// ResourceDefaultPolicySpec is a rule of the webhook policy.
+type ResourceDefaultPolicySpec struct {
+	// Priority orders the policies of the same kind, higher priorities first and equal ones by name.
+	Priority int32 `json:"priority,omitempty"`
+	// Match selects the containers, Namespaces is ignored for a namespaced ResourceDefaultPolicy.
+	Match PolicyMatch `json:"match,omitempty"`
+	// Defaults of the selected containers.
+	Defaults PolicyDefaults `json:"defaults"`
+}
+
+// PolicyMatch selects containers. Every given criterion has to match,
+// within a criterion any of the listed glob patterns is enough.
+type PolicyMatch struct {
+	Namespaces     []string          `json:"namespaces,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	ContainerNames []string          `json:"containerNames,omitempty"`
+	Images         []string          `json:"images,omitempty"`
+	// ImageRegexps are regular expressions matching the image, they aren't anchored.
+	ImageRegexps []string `json:"imageRegexps,omitempty"`
+	// Registries and Repositories are globs matching the parts of the normalized image.
+	Registries   []string `json:"registries,omitempty"`
+	Repositories []string `json:"repositories,omitempty"`
+	// Pinned matches how the image version is pinned: digest, tag or none.
+	Pinned []string `json:"pinned,omitempty"`
+}
+
+// PolicyDefaults are the default resources of the selected containers.
+type PolicyDefaults struct {
+	Containers k8s_v1.ResourceRequirements `json:"containers"`
+	// InitContainers defaults to Containers.
+	InitContainers *k8s_v1.ResourceRequirements `json:"initContainers,omitempty"`
+	// Min and Max bound the requests and limits of all kinds of containers.
+	Min k8s_v1.ResourceList `json:"min,omitempty"`
+	Max k8s_v1.ResourceList `json:"max,omitempty"`
+	// Clamp sets values out of bounds to the bound instead of denying the pod.
+	Clamp bool `json:"clamp,omitempty"`
+	// MaxLimitRequestRatio caps the limit of a resource to this multiple of its request.
+	MaxLimitRequestRatio k8s_v1.ResourceList `json:"maxLimitRequestRatio,omitempty"`
+	// RaiseRequests raises requests to limit / ratio instead of denying the pod.
+	RaiseRequests bool `json:"raiseRequests,omitempty"`
+	// RequestAboveLimit fixes a request greater than its limit by raising the limit (raiseLimit)
+	// or lowering the request (lowerRequest) instead of denying the pod.
+	RequestAboveLimit string `json:"requestAboveLimit,omitempty"`
+	// QoS Guaranteed sets the cpu/memory requests of containers and init containers to their limits.
+	QoS string `json:"qos,omitempty"`
+	// CPULimit none never adds cpu limits, strip also removes the cpu limits containers set.
+	CPULimit string `json:"cpuLimit,omitempty"`
+	// LimitFactors replace the default limit of a resource with its request times the factor.
+	LimitFactors k8s_v1.ResourceList `json:"limitFactors,omitempty"`
+}
+
+// ResourceDefaultPolicyStatus is reported by the webhook.
+type ResourceDefaultPolicyStatus struct {
+	// ObservedGeneration is the generation the status was reported for.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// DefaultedObjects counts the admitted objects (pods, workloads and custom resources) with resources defaulted by the policy.
+	// Pods created from an already defaulted template aren't counted again, server-side dry-runs aren't counted.
+	DefaultedObjects int64 `json:"defaultedObjects"`
+	// ValidationErrors of the spec, an invalid policy is ignored by the webhook.
+	ValidationErrors []string `json:"validationErrors,omitempty"`
+}
@@ -0,0 +1,73 @@
+package webhook
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	k8s_v1 "k8s.io/api/core/v1"
+)
+
+// Annotations marking the PodSpecs mutated by a pass of the webhook with the hash of its policy
+// and the comma separated names of the containers it handled.
+const (
+	appliedAnnotation           = annotationPrefix + "applied"
+	appliedContainersAnnotation = annotationPrefix + "applied-containers"
+)
+
+// appliedMarker stamps the PodSpecs mutated by a pass of the webhook, so a reinvocation
+// (reinvocationPolicy: IfNeeded) only handles containers added since, e.g. by a sidecar injector,
+// instead of undoing changes of other webhooks. An empty Hash disables the marker.
+type appliedMarker struct {
+	// Hash identifies the policy of the pass.
+	Hash string
+	// Reentry trusts the markers of earlier passes with the same policy when creating objects,
+	// the values of the containers they list are kept, missing ones are still defaulted.
+	// Updates of workloads re-evaluate all containers.
+	Reentry bool
+}
+
+// appliedContainers returns the containers of s handled by an earlier pass, if the marker is trusted.
+func (a appliedMarker) appliedContainers(s podSpec) map[string]bool {
+	applied := map[string]bool{}
+	if !a.Reentry || a.Hash == "" || s.Meta.Annotations[appliedAnnotation] != a.Hash {
+		return applied
+	}
+	for _, name := range strings.Split(s.Meta.Annotations[appliedContainersAnnotation], ",") {
+		if name != "" {
+			applied[name] = true
+		}
+	}
+
+	return applied
+}
+
+// annotations returns the marker annotations for the containers handled by this and earlier passes.
+func (a appliedMarker) annotations(applied map[string]bool) map[string]string {
+	names := []string{}
+	for name := range applied {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return map[string]string{
+		appliedAnnotation:           a.Hash,
+		appliedContainersAnnotation: strings.Join(names, ","),
+	}
+}
+
+// hash identifies the policy together with the namespace overrides, it changes with any rule, default or override.
+func (p Policy) hash(overrides k8s_v1.ResourceRequirements) (string, error) {
+	b, err := json.Marshal(struct {
+		Policy    Policy
+		Overrides k8s_v1.ResourceRequirements
+	}{Policy: p, Overrides: overrides})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:])[:16], nil
+}
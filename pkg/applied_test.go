@@ -0,0 +1,190 @@
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+
+	k8s_v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func Test_createResponse_reinvocation(t *testing.T) {
+	pod := schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	first := podSpec{
+		Path: "/spec",
+		Spec: k8s_v1.PodSpec{Containers: []k8s_v1.Container{{Name: "app"}}},
+		Meta: metav1.ObjectMeta{Name: "app"},
+	}
+	marker := appliedMarker{Hash: "h1", Reentry: true}
+	resp, _, err := createResponse([]podSpec{first}, staticDefaults(Defaults{Containers: defaults}), ComplementToDefault{}, marker)
+	if err != nil {
+		t.Fatalf("createResponse() error = %v", err)
+	}
+	patched := applyTestPatch(t, testObject(t, first), resp.Patch)
+
+	// a sidecar injector raises the memory limit of app and adds a proxy
+	obj := k8s_v1.Pod{}
+	if err := json.Unmarshal(patched, &obj); err != nil {
+		t.Fatalf("failed to decode patched object: %v", err)
+	}
+	if got := obj.Annotations[appliedContainersAnnotation]; obj.Annotations[appliedAnnotation] != "h1" || got != "app" {
+		t.Fatalf("createResponse() annotations = %v, want the marker for app", obj.Annotations)
+	}
+	obj.Spec.Containers[0].Resources.Limits[k8s_v1.ResourceMemory] = getResourceQuantity("2G")
+	obj.Spec.Containers = append(obj.Spec.Containers, k8s_v1.Container{Name: "proxy"})
+	injected, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		marker    appliedMarker
+		want      map[string]k8s_v1.ResourceRequirements
+		wantNames string
+		// the record of the first pass is kept by a reinvocation
+		wantDefaulted string
+	}{
+		{
+			name:   "reinvocation keeps marked containers and defaults added ones",
+			marker: marker,
+			want: map[string]k8s_v1.ResourceRequirements{
+				"/spec/containers/1/resources": defaults,
+			},
+			wantNames:     "app,proxy",
+			wantDefaulted: `{"app":["limits.cpu","limits.memory","requests.cpu","requests.memory"],"proxy":["limits.cpu","limits.memory","requests.cpu","requests.memory"]}`,
+		},
+		{
+			name:   "changed policy re-evaluates all containers",
+			marker: appliedMarker{Hash: "h2", Reentry: true},
+			// app has all of its values, so only the proxy gets defaulted
+			want: map[string]k8s_v1.ResourceRequirements{
+				"/spec/containers/1/resources": defaults,
+			},
+			wantNames:     "app,proxy",
+			wantDefaulted: `{"proxy":["limits.cpu","limits.memory","requests.cpu","requests.memory"]}`,
+		},
+		{
+			name:   "updates re-evaluate all containers",
+			marker: appliedMarker{Hash: "h1"},
+			// app has all of its values, so only the proxy gets defaulted
+			want: map[string]k8s_v1.ResourceRequirements{
+				"/spec/containers/1/resources": defaults,
+			},
+			wantNames:     "app,proxy",
+			wantDefaulted: `{"proxy":["limits.cpu","limits.memory","requests.cpu","requests.memory"]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			specs, err := findPodSpecs(pod, injected, nil)
+			if err != nil {
+				t.Fatalf("findPodSpecs() error = %v", err)
+			}
+			resp, _, err := createResponse(specs, staticDefaults(Defaults{Containers: defaults}), ComplementToDefault{}, tt.marker)
+			if err != nil {
+				t.Fatalf("createResponse() error = %v", err)
+			}
+			repatched := applyTestPatch(t, injected, resp.Patch)
+			got := patchedTestResources(t, "/spec", injected, repatched)
+			if len(got) != len(tt.want) {
+				t.Errorf("createResponse() patched %v, want %v", got, tt.want)
+			}
+			for path, want := range tt.want {
+				if !equalResources(got[path], want) {
+					t.Errorf("createResponse() %s = %v, want %v", path, got[path], want)
+				}
+			}
+
+			obj := k8s_v1.Pod{}
+			if err := json.Unmarshal(repatched, &obj); err != nil {
+				t.Fatalf("failed to decode patched object: %v", err)
+			}
+			if got := obj.Annotations[appliedContainersAnnotation]; obj.Annotations[appliedAnnotation] != tt.marker.Hash || got != tt.wantNames {
+				t.Errorf("createResponse() annotations = %v, want %s for %s", obj.Annotations, tt.marker.Hash, tt.wantNames)
+			}
+			if got := obj.Annotations[defaultedAnnotation]; got != tt.wantDefaulted {
+				t.Errorf("createResponse() defaulted = %s, want %s", got, tt.wantDefaulted)
+			}
+		})
+	}
+}
+
+func Test_createResponse_reinvocationWithoutChanges(t *testing.T) {
+	s := podSpec{
+		Path: "/spec",
+		Spec: k8s_v1.PodSpec{Containers: []k8s_v1.Container{{Name: "app", Resources: defaults}}},
+		Meta: metav1.ObjectMeta{Annotations: map[string]string{
+			appliedAnnotation:           "h1",
+			appliedContainersAnnotation: "app",
+			fixesAnnotation:             `{"app":["raised limits.memory from 1G to 2G"]}`,
+		}},
+	}
+	resp, _, err := createResponse([]podSpec{s}, staticDefaults(Defaults{Containers: defaults}), ComplementToDefault{}, appliedMarker{Hash: "h1", Reentry: true})
+	if err != nil {
+		t.Fatalf("createResponse() error = %v", err)
+	}
+	if resp.Patch != nil {
+		t.Errorf("createResponse() patch = %s, want none", resp.Patch)
+	}
+}
+
+func TestPolicy_hash(t *testing.T) {
+	p := Policy{Default: Defaults{Containers: defaults}}
+	a, err := p.hash(k8s_v1.ResourceRequirements{})
+	if err != nil {
+		t.Fatalf("hash() error = %v", err)
+	}
+	if again, _ := p.hash(k8s_v1.ResourceRequirements{}); again != a {
+		t.Errorf("hash() = %s, then %s", a, again)
+	}
+	if o, _ := p.hash(parseTestResourceRequirements("2G", "", "", "")); o == a {
+		t.Errorf("hash() = %s for changed overrides", o)
+	}
+	p.Rules = []Rule{{Name: "a", Defaults: Defaults{Containers: defaults}}}
+	if b, _ := p.hash(k8s_v1.ResourceRequirements{}); b == a {
+		t.Errorf("hash() = %s for a changed policy", b)
+	}
+}
+
+func Test_createResponse_markedContainersKeepConstraints(t *testing.T) {
+	s := podSpec{
+		Path: "/spec",
+		Spec: k8s_v1.PodSpec{Containers: []k8s_v1.Container{{Name: "app", Resources: parseTestResourceRequirements("4G", "", "", "")}}},
+		// e.g. copied from a defaulted pod
+		Meta: metav1.ObjectMeta{Annotations: map[string]string{
+			appliedAnnotation:           "h1",
+			appliedContainersAnnotation: "app",
+		}},
+	}
+	d := Defaults{Containers: defaults, Max: k8s_v1.ResourceList{k8s_v1.ResourceMemory: getResourceQuantity("2G")}}
+	resp, _, err := createResponse([]podSpec{s}, staticDefaults(d), ComplementToDefault{}, appliedMarker{Hash: "h1", Reentry: true})
+	if err != nil {
+		t.Fatalf("createResponse() error = %v", err)
+	}
+	if resp.Allowed {
+		t.Errorf("createResponse() allowed a marked container above the maximum")
+	}
+}
+
+func Test_createResponse_forgedMarker(t *testing.T) {
+	s := podSpec{
+		Path: "/spec",
+		Spec: k8s_v1.PodSpec{Containers: []k8s_v1.Container{{Name: "app"}}},
+		// copied from a defaulted pod, but without its resources
+		Meta: metav1.ObjectMeta{Annotations: map[string]string{
+			appliedAnnotation:           "h1",
+			appliedContainersAnnotation: "app",
+		}},
+	}
+	resp, _, err := createResponse([]podSpec{s}, staticDefaults(Defaults{Containers: defaults}), ComplementToDefault{}, appliedMarker{Hash: "h1", Reentry: true})
+	if err != nil {
+		t.Fatalf("createResponse() error = %v", err)
+	}
+	doc := testObject(t, s)
+	got := patchedTestResources(t, s.Path, doc, applyTestPatch(t, doc, resp.Patch))
+	if !equalResources(got["/spec/containers/0/resources"], defaults) {
+		t.Errorf("createResponse() patched %v, want the defaults for app", got)
+	}
+}
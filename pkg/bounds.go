@@ -0,0 +1,70 @@
+package webhook
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	k8s_v1 "k8s.io/api/core/v1"
+)
+
+// enforceBounds checks the requests and limits set in r against the Min and Max of d, like a LimitRange does.
+// With d.Clamp values out of bounds are set to the bound, otherwise all of them are returned as one error.
+func (d Defaults) enforceBounds(r k8s_v1.ResourceRequirements) (k8s_v1.ResourceRequirements, error) {
+	r = *r.DeepCopy()
+	violations := []string{}
+	for _, l := range []struct {
+		field string
+		list  k8s_v1.ResourceList
+	}{
+		{field: "requests", list: r.Requests},
+		{field: "limits", list: r.Limits},
+	} {
+		for _, name := range sortedResourceNames(l.list) {
+			q := l.list[name]
+			if min, found := d.Min[name]; found && q.Cmp(min) < 0 {
+				if d.Clamp {
+					l.list[name] = min.DeepCopy()
+					continue
+				}
+				violations = append(violations, fmt.Sprintf("%s.%s %s is less than the minimum %s", l.field, name, q.String(), min.String()))
+			}
+			if max, found := d.Max[name]; found && q.Cmp(max) > 0 {
+				if d.Clamp {
+					l.list[name] = max.DeepCopy()
+					continue
+				}
+				violations = append(violations, fmt.Sprintf("%s.%s %s is greater than the maximum %s", l.field, name, q.String(), max.String()))
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return r, fmt.Errorf("%s", strings.Join(violations, ", "))
+	}
+
+	return r, nil
+}
+
+// validateBounds checks that no minimum is greater than its maximum.
+func (d Defaults) validateBounds() error {
+	for _, name := range sortedResourceNames(d.Min) {
+		min := d.Min[name]
+		if max, found := d.Max[name]; found && min.Cmp(max) > 0 {
+			return fmt.Errorf("minimum %s is greater than the maximum %s", name, name)
+		}
+	}
+
+	return nil
+}
+
+// sortedResourceNames returns the resource names of l in a stable order for messages.
+func sortedResourceNames(l k8s_v1.ResourceList) []k8s_v1.ResourceName {
+	names := []k8s_v1.ResourceName{}
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+
+	return names
+}
@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"strings"
+	"testing"
+
+	k8s_v1 "k8s.io/api/core/v1"
+)
+
+func TestDefaults_enforceBounds(t *testing.T) {
+	bounds := Defaults{
+		Min: k8s_v1.ResourceList{k8s_v1.ResourceCPU: getResourceQuantity("10m")},
+		Max: k8s_v1.ResourceList{k8s_v1.ResourceCPU: getResourceQuantity("4"), k8s_v1.ResourceMemory: getResourceQuantity("8Gi")},
+	}
+	clamp := bounds
+	clamp.Clamp = true
+
+	tests := []struct {
+		name       string
+		d          Defaults
+		r          k8s_v1.ResourceRequirements
+		want       k8s_v1.ResourceRequirements
+		wantErrors []string
+	}{
+		{
+			name: "within bounds",
+			d:    bounds,
+			r:    parseTestResourceRequirements("1G", "1", "512M", "0.1"),
+			want: parseTestResourceRequirements("1G", "1", "512M", "0.1"),
+		},
+		{
+			name: "no bounds",
+			d:    Defaults{},
+			r:    parseTestResourceRequirements("", "500", "", ""),
+			want: parseTestResourceRequirements("", "500", "", ""),
+		},
+		{
+			name:       "deny lists every offending field",
+			d:          bounds,
+			r:          parseTestResourceRequirements("16Gi", "500", "", "1m"),
+			wantErrors: []string{"requests.cpu 1m is less than the minimum 10m", "limits.cpu 500 is greater than the maximum 4", "limits.memory 16Gi is greater than the maximum 8Gi"},
+		},
+		{
+			name: "clamp",
+			d:    clamp,
+			r:    parseTestResourceRequirements("16Gi", "500", "", "1m"),
+			want: parseTestResourceRequirements("8Gi", "4", "", "10m"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.d.enforceBounds(tt.r)
+			if len(tt.wantErrors) > 0 {
+				if err == nil {
+					t.Fatalf("enforceBounds() error = nil, want %v", tt.wantErrors)
+				}
+				for _, want := range tt.wantErrors {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("enforceBounds() error = %q, want it to contain %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("enforceBounds() error = %v", err)
+			}
+			if !equalResources(got, tt.want) {
+				t.Errorf("enforceBounds() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_createResponse_deniesAllOffendingContainers(t *testing.T) {
+	d := Defaults{
+		Containers: defaults,
+		Max:        k8s_v1.ResourceList{k8s_v1.ResourceCPU: getResourceQuantity("4")},
+	}
+	spec := k8s_v1.PodSpec{
+		Containers: []k8s_v1.Container{
+			{Name: "app", Resources: parseTestResourceRequirements("", "500", "", "")},
+			{Name: "ok"},
+			{Name: "worker", Resources: parseTestResourceRequirements("", "8", "", "")},
+		},
+	}
+
+	resp, _, err := createResponse([]podSpec{{Path: "/spec", Spec: spec}}, staticDefaults(d), ComplementToDefault{}, appliedMarker{})
+	if err != nil {
+		t.Fatalf("createResponse() error = %v", err)
+	}
+	if resp.Allowed {
+		t.Fatal("createResponse() allowed a pod out of bounds")
+	}
+	for _, want := range []string{`container "app": limits.cpu 500`, `container "worker": limits.cpu 8`} {
+		if !strings.Contains(resp.Result.Message, want) {
+			t.Errorf("createResponse() message = %q, want it to contain %q", resp.Result.Message, want)
+		}
+	}
+	if strings.Contains(resp.Result.Message, `"ok"`) {
+		t.Errorf("createResponse() message = %q, names a container within bounds", resp.Result.Message)
+	}
+}
@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the content of the YAML (or JSON) file passed with -config.
+type Config struct {
+	// PodSpecPaths locates the PodSpecs of custom resources, e.g. Argo Rollouts or Knative Services.
+	PodSpecPaths []PodSpecPath `json:"podSpecPaths,omitempty"`
+	// Rules select the defaults of containers, the first matching rule wins.
+	Rules []Rule `json:"rules,omitempty"`
+	// Default replaces the defaults given by program flags for containers no rule matches.
+	Default *Defaults `json:"default,omitempty"`
+	// Profiles are named defaults pods select per container with the annotation default-resources.io/profile.<container>.
+	Profiles map[string]Defaults `json:"profiles,omitempty"`
+	// BuiltinSidecars puts the built-in rules for well-known sidecars before the rules.
+	BuiltinSidecars bool `json:"builtinSidecars,omitempty"`
+	// Sidecars replace the defaults of built-in sidecar rules by sidecar name, e.g. istio-proxy.
+	Sidecars map[string]Defaults `json:"sidecars,omitempty"`
+	// AllowPodAnnotations lets pods skip containers and select profiles by annotation, see podannotations.go.
+	AllowPodAnnotations bool `json:"allowPodAnnotations,omitempty"`
+}
+
+// PodSpecPath maps a group/version/kind to the JSON pointers of the PodSpecs its objects embed.
+type PodSpecPath struct {
+	Group string `json:"group"`
+	// Version is optional, an empty version matches all versions of the kind.
+	Version string   `json:"version,omitempty"`
+	Kind    string   `json:"kind"`
+	Paths   []string `json:"paths"`
+}
+
+// LoadConfig reads and validates the config file at path.
+func LoadConfig(path string) (Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read config file: %s", err)
+	}
+
+	return ParseConfig(b)
+}
+
+// ParseConfig parses and validates a YAML or JSON config.
+func ParseConfig(b []byte) (Config, error) {
+	c := Config{}
+	if err := yaml.UnmarshalStrict(b, &c); err != nil {
+		return Config{}, fmt.Errorf("failed to parse config: %s", err)
+	}
+
+	for i, p := range c.PodSpecPaths {
+		if p.Kind == "" {
+			return Config{}, fmt.Errorf("podSpecPaths[%d]: kind is required", i)
+		}
+		if len(p.Paths) == 0 {
+			return Config{}, fmt.Errorf("podSpecPaths[%d]: at least one path is required", i)
+		}
+		for _, path := range p.Paths {
+			if !strings.HasPrefix(path, "/") {
+				return Config{}, fmt.Errorf("podSpecPaths[%d]: path %q is no JSON pointer", i, path)
+			}
+		}
+	}
+
+	if err := validateRules(c.Rules); err != nil {
+		return Config{}, err
+	}
+	if c.Default != nil {
+		if err := c.Default.validate(); err != nil {
+			return Config{}, fmt.Errorf("default: %s", err)
+		}
+	}
+	if err := validateSidecars(c.Sidecars); err != nil {
+		return Config{}, err
+	}
+	for name, d := range c.Profiles {
+		if err := d.validate(); err != nil {
+			return Config{}, fmt.Errorf("profiles %s: %s", name, err)
+		}
+	}
+
+	return c, nil
+}
+
+// Policy returns the policy of the config, falling back to defaults for containers no rule matches.
+func (c Config) Policy(defaults Defaults) Policy {
+	if c.Default != nil {
+		defaults = *c.Default
+	}
+
+	rules := c.Rules
+	if c.BuiltinSidecars {
+		// sidecar rules are more specific than most rules, e.g. by namespace
+		rules = append(builtinSidecarRules(c.Sidecars), c.Rules...)
+	}
+
+	return Policy{Rules: rules, Default: defaults, Profiles: c.Profiles, AllowPodAnnotations: c.AllowPodAnnotations}
+}
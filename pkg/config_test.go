@@ -0,0 +1,161 @@
+package webhook
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    Config
+		wantErr bool
+	}{
+		{
+			name:   "empty config",
+			config: ``,
+			want:   Config{},
+		},
+		{
+			name: "yaml podSpecPaths",
+			config: `
+podSpecPaths:
+  - group: argoproj.io
+    kind: Rollout
+    paths:
+      - /spec/template/spec
+`,
+			want: Config{
+				PodSpecPaths: []PodSpecPath{
+					{Group: "argoproj.io", Kind: "Rollout", Paths: []string{"/spec/template/spec"}},
+				},
+			},
+		},
+		{
+			name:   "json podSpecPaths",
+			config: `{"podSpecPaths":[{"group":"serving.knative.dev","version":"v1","kind":"Service","paths":["/spec/template/spec"]}]}`,
+			want: Config{
+				PodSpecPaths: []PodSpecPath{
+					{Group: "serving.knative.dev", Version: "v1", Kind: "Service", Paths: []string{"/spec/template/spec"}},
+				},
+			},
+		},
+		{
+			name:    "unknown field",
+			config:  `podSpecPath: []`,
+			wantErr: true,
+		},
+		{
+			name:    "missing kind",
+			config:  `{"podSpecPaths":[{"group":"argoproj.io","paths":["/spec/template/spec"]}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "missing paths",
+			config:  `{"podSpecPaths":[{"group":"argoproj.io","kind":"Rollout"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "path is no JSON pointer",
+			config:  `{"podSpecPaths":[{"group":"argoproj.io","kind":"Rollout","paths":["spec.template.spec"]}]}`,
+			wantErr: true,
+		},
+		{
+			name: "rules",
+			config: `
+rules:
+  - name: kube-system
+    match:
+      namespaces: ["kube-*"]
+    defaults:
+      containers: {}
+`,
+			want: Config{
+				Rules: []Rule{
+					{Name: "kube-system", Match: testCompiledMatch(Match{Namespaces: []string{"kube-*"}})},
+				},
+			},
+		},
+		{
+			name:    "rule without name",
+			config:  `{"rules":[{"defaults":{"containers":{}}}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "rules with the same name",
+			config:  `{"rules":[{"name":"a","defaults":{"containers":{}}},{"name":"a","defaults":{"containers":{}}}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "rule named like the default",
+			config:  `{"rules":[{"name":"default","defaults":{"containers":{}}}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "rule requests greater than limits",
+			config:  `{"rules":[{"name":"a","defaults":{"containers":{"limits":{"cpu":"100m"},"requests":{"cpu":"1"}}}}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "rule without cpu limits and Guaranteed QoS",
+			config:  `{"rules":[{"name":"a","defaults":{"containers":{},"cpuLimit":"none","qos":"Guaranteed"}}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "rule limitFactors and Guaranteed QoS",
+			config:  `{"rules":[{"name":"a","defaults":{"containers":{},"qos":"Guaranteed","limitFactors":{"memory":"1.5"}}}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "rule limitFactor less than 1",
+			config:  `{"rules":[{"name":"a","defaults":{"containers":{},"limitFactors":{"memory":"0.9"}}}]}`,
+			wantErr: true,
+		},
+		{
+			name: "profiles",
+			config: `
+allowPodAnnotations: true
+profiles:
+  large:
+    containers: {}
+`,
+			want: Config{
+				Profiles:            map[string]Defaults{"large": {}},
+				AllowPodAnnotations: true,
+			},
+		},
+		{
+			name:    "profile requests greater than limits",
+			config:  `{"profiles":{"large":{"containers":{"limits":{"cpu":"100m"},"requests":{"cpu":"1"}}}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "rule with invalid image regexp",
+			config:  `{"rules":[{"name":"a","match":{"imageRegexps":["(openjdk"]},"defaults":{"containers":{}}}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "default requests greater than limits",
+			config:  `{"default":{"containers":{"limits":{"memory":"1Gi"},"requests":{"memory":"2Gi"}}}}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConfig([]byte(tt.config))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// testCompiledMatch returns m with its patterns compiled, like validated rules have them.
+func testCompiledMatch(m Match) Match {
+	m.compiled, _ = m.compile()
+	return m
+}
@@ -0,0 +1,37 @@
+package webhook
+
+import (
+	"fmt"
+
+	k8s_v1 "k8s.io/api/core/v1"
+)
+
+// Values of Defaults.CPULimit.
+const (
+	// cpuLimitNone never adds a cpu limit, but keeps the ones containers set.
+	cpuLimitNone = "none"
+	// cpuLimitStrip never adds a cpu limit and removes the ones containers set.
+	cpuLimitStrip = "strip"
+)
+
+// withoutCPULimit returns r without its cpu limit.
+func withoutCPULimit(r k8s_v1.ResourceRequirements) k8s_v1.ResourceRequirements {
+	r = *r.DeepCopy()
+	delete(r.Limits, k8s_v1.ResourceCPU)
+	return r
+}
+
+// validateCPULimit checks d.CPULimit is a known value, which doesn't contradict the QoS.
+func (d Defaults) validateCPULimit() error {
+	switch d.CPULimit {
+	case "":
+		return nil
+	case cpuLimitNone, cpuLimitStrip:
+		if d.QoS == qosGuaranteed {
+			return fmt.Errorf("cpuLimit %s contradicts qos %s, which needs cpu limits", d.CPULimit, qosGuaranteed)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("cpuLimit %q is none of %s, %s", d.CPULimit, cpuLimitNone, cpuLimitStrip)
+}
@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"testing"
+
+	k8s_v1 "k8s.io/api/core/v1"
+)
+
+func Test_resolveResources_cpuLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		cpuLimit string
+		strategy Strategy
+		c        k8s_v1.ResourceRequirements
+		want     k8s_v1.ResourceRequirements
+	}{
+		{
+			name:     "none doesn't default the cpu limit",
+			cpuLimit: cpuLimitNone,
+			strategy: ComplementToDefault{},
+			c:        k8s_v1.ResourceRequirements{},
+			want:     parseTestResourceRequirements(limitMemory, "", requestMemory, requestCPU),
+		},
+		{
+			name:     "none keeps the containers cpu limit",
+			cpuLimit: cpuLimitNone,
+			strategy: ComplementToDefault{},
+			c:        parseTestResourceRequirements("", "2", "", ""),
+			want:     parseTestResourceRequirements(limitMemory, "2", requestMemory, requestCPU),
+		},
+		{
+			name:     "none doesn't derive the cpu limit from the request",
+			cpuLimit: cpuLimitNone,
+			strategy: Derive{Strategy: ComplementToDefault{}},
+			c:        parseTestResourceRequirements("", "", "", "2"),
+			want:     parseTestResourceRequirements(limitMemory, "", requestMemory, "2"),
+		},
+		{
+			name:     "strip removes the containers cpu limit",
+			cpuLimit: cpuLimitStrip,
+			strategy: ComplementToDefault{},
+			c:        parseTestResourceRequirements("2G", "2", "", "4"),
+			want:     parseTestResourceRequirements("2G", "", requestMemory, "4"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Defaults{Containers: defaults, CPULimit: tt.cpuLimit}
+			got, _, err := resolveResources(tt.c, defaults, p, tt.strategy)
+			if err != nil {
+				t.Fatalf("resolveResources() error = %v", err)
+			}
+			if !equalResources(got, tt.want) {
+				t.Errorf("resolveResources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"fmt"
+
+	"gopkg.in/inf.v0"
+	k8s_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// applyLimitFactors sets the limits of the resources with a factor in d.LimitFactors to request * factor,
+// unless the container sets the limit itself (in c). The products are exact, only rounded up like minRequest.
+func (d Defaults) applyLimitFactors(c k8s_v1.ResourceRequirements, r k8s_v1.ResourceRequirements) k8s_v1.ResourceRequirements {
+	r = *r.DeepCopy()
+	for _, name := range sortedResourceNames(d.LimitFactors) {
+		if _, found := c.Limits[name]; found {
+			continue
+		}
+		request, found := r.Requests[name]
+		if !found {
+			continue
+		}
+
+		factor := d.LimitFactors[name]
+		limit := new(inf.Dec).Mul(decimal(request), decimal(factor))
+		limit.Round(limit, resourceScale(name), inf.RoundUp)
+		if r.Limits == nil {
+			r.Limits = k8s_v1.ResourceList{}
+		}
+		r.Limits[name] = *resource.NewDecimalQuantity(*limit, request.Format)
+	}
+
+	return r
+}
+
+// withoutFactorLimits returns the defaults r without the limits computed from a factor.
+func (d Defaults) withoutFactorLimits(r k8s_v1.ResourceRequirements) k8s_v1.ResourceRequirements {
+	r = *r.DeepCopy()
+	for name := range d.LimitFactors {
+		delete(r.Limits, name)
+	}
+	return r
+}
+
+// validateLimitFactors checks that no factor is less than 1, which would make limits less than their requests,
+// and that no factors are combined with the Guaranteed QoS, which needs limits equal to the requests.
+func (d Defaults) validateLimitFactors() error {
+	if len(d.LimitFactors) > 0 && d.QoS == qosGuaranteed {
+		return fmt.Errorf("limitFactors contradict qos %s, which sets requests to the limits", qosGuaranteed)
+	}
+	one := resource.MustParse("1")
+	for _, name := range sortedResourceNames(d.LimitFactors) {
+		factor := d.LimitFactors[name]
+		if factor.Cmp(one) < 0 {
+			return fmt.Errorf("limitFactor of %s is less than 1", name)
+		}
+	}
+
+	return nil
+}
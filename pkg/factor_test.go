@@ -0,0 +1,124 @@
+package webhook
+
+import (
+	"testing"
+
+	k8s_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func Test_resolveResources_limitFactors(t *testing.T) {
+	factors := k8s_v1.ResourceList{
+		k8s_v1.ResourceMemory: resource.MustParse("1.5"),
+		k8s_v1.ResourceCPU:    resource.MustParse("4"),
+	}
+	tests := []struct {
+		name     string
+		factors  k8s_v1.ResourceList
+		cpuLimit string
+		strategy Strategy
+		c        k8s_v1.ResourceRequirements
+		want     k8s_v1.ResourceRequirements
+	}{
+		{
+			name:     "factors of defaulted requests",
+			factors:  factors,
+			strategy: ComplementToDefault{},
+			c:        k8s_v1.ResourceRequirements{},
+			want:     parseTestResourceRequirements("1.5G", "400m", requestMemory, requestCPU),
+		},
+		{
+			name:     "factors of container requests",
+			factors:  factors,
+			strategy: ComplementToDefault{},
+			c:        parseTestResourceRequirements("", "", "512Mi", "30m"),
+			want:     parseTestResourceRequirements("768Mi", "120m", "512Mi", "30m"),
+		},
+		{
+			name:     "container limits are kept",
+			factors:  factors,
+			strategy: ComplementToDefault{},
+			c:        parseTestResourceRequirements("4G", "", "2G", ""),
+			want:     parseTestResourceRequirements("4G", "400m", "2G", requestCPU),
+		},
+		{
+			name:     "default limit isn't checked against container request",
+			factors:  factors,
+			strategy: ComplementToDefault{},
+			c:        parseTestResourceRequirements("", "", "2G", ""),
+			want:     parseTestResourceRequirements("3G", "400m", "2G", requestCPU),
+		},
+		{
+			name:     "factors replace derived limits",
+			factors:  factors,
+			strategy: Derive{Strategy: ComplementToDefault{}},
+			c:        parseTestResourceRequirements("", "", "", "1"),
+			want:     parseTestResourceRequirements("1.5G", "4", requestMemory, "1"),
+		},
+		{
+			name:     "products are rounded up to whole units",
+			factors:  k8s_v1.ResourceList{k8s_v1.ResourceMemory: resource.MustParse("1.5")},
+			strategy: ComplementToDefault{},
+			c:        parseTestResourceRequirements("", "", "333", ""),
+			want:     parseTestResourceRequirements("500", limitCPU, "333", requestCPU),
+		},
+		{
+			name:     "products are rounded up to millicores",
+			factors:  k8s_v1.ResourceList{k8s_v1.ResourceCPU: resource.MustParse("1.5")},
+			strategy: ComplementToDefault{},
+			c:        parseTestResourceRequirements("", "", "", "3m"),
+			want:     parseTestResourceRequirements(limitMemory, "5m", requestMemory, "3m"),
+		},
+		{
+			name:     "resources without factor keep their default limit",
+			factors:  k8s_v1.ResourceList{k8s_v1.ResourceCPU: resource.MustParse("2")},
+			strategy: ComplementToDefault{},
+			c:        k8s_v1.ResourceRequirements{},
+			want:     parseTestResourceRequirements(limitMemory, "200m", requestMemory, requestCPU),
+		},
+		{
+			name:     "no cpu limit despite factor",
+			factors:  factors,
+			cpuLimit: cpuLimitNone,
+			strategy: ComplementToDefault{},
+			c:        k8s_v1.ResourceRequirements{},
+			want:     parseTestResourceRequirements("1.5G", "", requestMemory, requestCPU),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Defaults{Containers: defaults, LimitFactors: tt.factors, CPULimit: tt.cpuLimit}
+			got, _, err := resolveResources(tt.c, defaults, p, tt.strategy)
+			if err != nil {
+				t.Fatalf("resolveResources() error = %v", err)
+			}
+			if !equalResources(got, tt.want) {
+				t.Errorf("resolveResources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaults_validateLimitFactors(t *testing.T) {
+	tests := []struct {
+		name    string
+		factors k8s_v1.ResourceList
+		qos     string
+		wantErr bool
+	}{
+		{name: "no factors"},
+		{name: "factor of 1", factors: k8s_v1.ResourceList{k8s_v1.ResourceCPU: resource.MustParse("1")}},
+		{name: "fractional factor", factors: k8s_v1.ResourceList{k8s_v1.ResourceMemory: resource.MustParse("1.25")}},
+		{name: "factor less than 1", factors: k8s_v1.ResourceList{k8s_v1.ResourceMemory: resource.MustParse("0.5")}, wantErr: true},
+		{name: "Guaranteed QoS without factors", qos: qosGuaranteed},
+		{name: "factor and Guaranteed QoS", factors: k8s_v1.ResourceList{k8s_v1.ResourceMemory: resource.MustParse("1.5")}, qos: qosGuaranteed, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Defaults{LimitFactors: tt.factors, QoS: tt.qos}.validateLimitFactors()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateLimitFactors() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package webhook
+
+import (
+	"fmt"
+
+	k8s_v1 "k8s.io/api/core/v1"
+)
+
+// Values of Defaults.RequestAboveLimit.
+const (
+	raiseLimit   = "raiseLimit"
+	lowerRequest = "lowerRequest"
+)
+
+// fixesAnnotation records the fixed requests above their limit per container name on the pod (template).
+const fixesAnnotation = annotationPrefix + "fixed"
+
+// fixRequestsAboveLimits resolves requests greater than their limit as configured by d.RequestAboveLimit
+// and describes the changes made.
+func (d Defaults) fixRequestsAboveLimits(r k8s_v1.ResourceRequirements) (k8s_v1.ResourceRequirements, []string) {
+	r = *r.DeepCopy()
+	fixes := []string{}
+	for _, name := range sortedResourceNames(r.Requests) {
+		request := r.Requests[name]
+		limit, found := r.Limits[name]
+		if !found || request.Cmp(limit) <= 0 {
+			continue
+		}
+
+		switch d.RequestAboveLimit {
+		case raiseLimit:
+			r.Limits[name] = request.DeepCopy()
+			fixes = append(fixes, fmt.Sprintf("raised limits.%s from %s to %s", name, limit.String(), request.String()))
+		case lowerRequest:
+			r.Requests[name] = limit.DeepCopy()
+			fixes = append(fixes, fmt.Sprintf("lowered requests.%s from %s to %s", name, request.String(), limit.String()))
+		}
+	}
+
+	return r, fixes
+}
+
+// validateRequestAboveLimit checks d.RequestAboveLimit is a known value.
+func (d Defaults) validateRequestAboveLimit() error {
+	switch d.RequestAboveLimit {
+	case "", raiseLimit, lowerRequest:
+		return nil
+	}
+
+	return fmt.Errorf("requestAboveLimit %q is none of %s, %s", d.RequestAboveLimit, raiseLimit, lowerRequest)
+}
@@ -0,0 +1,112 @@
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+
+	k8s_v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDefaults_fixRequestsAboveLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		r         k8s_v1.ResourceRequirements
+		want      k8s_v1.ResourceRequirements
+		wantFixes []string
+	}{
+		{
+			name:      "raise limit",
+			mode:      raiseLimit,
+			r:         parseTestResourceRequirements("512M", "0.5", "1G", "0.1"),
+			want:      parseTestResourceRequirements("1G", "0.5", "1G", "0.1"),
+			wantFixes: []string{"raised limits.memory from 512M to 1G"},
+		},
+		{
+			name:      "lower request",
+			mode:      lowerRequest,
+			r:         parseTestResourceRequirements("512M", "0.5", "1G", "1"),
+			want:      parseTestResourceRequirements("512M", "0.5", "512M", "0.5"),
+			wantFixes: []string{"lowered requests.cpu from 1 to 500m", "lowered requests.memory from 1G to 512M"},
+		},
+		{
+			name:      "nothing to fix",
+			mode:      raiseLimit,
+			r:         parseTestResourceRequirements("1G", "0.5", "1G", "0.1"),
+			want:      parseTestResourceRequirements("1G", "0.5", "1G", "0.1"),
+			wantFixes: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, fixes := Defaults{RequestAboveLimit: tt.mode}.fixRequestsAboveLimits(tt.r)
+			if !equalResources(got, tt.want) {
+				t.Errorf("fixRequestsAboveLimits() = %v, want %v", got, tt.want)
+			}
+			if len(fixes) != len(tt.wantFixes) {
+				t.Fatalf("fixRequestsAboveLimits() fixes = %v, want %v", fixes, tt.wantFixes)
+			}
+			for i := range fixes {
+				if fixes[i] != tt.wantFixes[i] {
+					t.Errorf("fixRequestsAboveLimits() fixes = %v, want %v", fixes, tt.wantFixes)
+				}
+			}
+		})
+	}
+}
+
+func Test_createResponse_fixesRequestAboveLimit(t *testing.T) {
+	d := Defaults{Containers: defaults, RequestAboveLimit: raiseLimit}
+	spec := podSpec{
+		Path: "/spec/template/spec",
+		Spec: k8s_v1.PodSpec{Containers: []k8s_v1.Container{
+			{Name: "app", Resources: parseTestResourceRequirements("", "", "2G", "")},
+		}},
+		Meta: metav1.ObjectMeta{Annotations: map[string]string{"team": "a"}},
+	}
+
+	resp, _, err := createResponse([]podSpec{spec}, staticDefaults(d), ComplementToDefault{}, appliedMarker{})
+	if err != nil {
+		t.Fatalf("createResponse() error = %v", err)
+	}
+	if !resp.Allowed {
+		t.Fatalf("createResponse() denied: %v", resp.Result)
+	}
+
+	doc := testObject(t, spec)
+	patched := applyTestPatch(t, doc, resp.Patch)
+	resources := patchedTestResources(t, spec.Path, doc, patched)["/spec/template/spec/containers/0/resources"]
+	if limit := resources.Limits[k8s_v1.ResourceMemory]; limit.Cmp(getResourceQuantity("2G")) != 0 {
+		t.Errorf("createResponse() limits.memory = %s, want 2G", limit.String())
+	}
+
+	obj := struct {
+		Spec struct {
+			Template struct {
+				Metadata metav1.ObjectMeta `json:"metadata"`
+			} `json:"template"`
+		} `json:"spec"`
+	}{}
+	if err := json.Unmarshal(patched, &obj); err != nil {
+		t.Fatalf("failed to decode patched object: %v", err)
+	}
+	annotation := obj.Spec.Template.Metadata.Annotations[fixesAnnotation]
+	if want := `{"app":["raised limits.memory from 1G to 2G"]}`; annotation != want {
+		t.Errorf("createResponse() annotation = %s, want %s", annotation, want)
+	}
+}
+
+func Test_resolveResources_fixBeforeGuaranteed(t *testing.T) {
+	p := Defaults{Containers: defaults, QoS: qosGuaranteed, RequestAboveLimit: raiseLimit}
+	got, fixes, err := resolveResources(parseTestResourceRequirements("", "", "2G", ""), defaults, p, ComplementToDefault{})
+	if err != nil {
+		t.Fatalf("resolveResources() error = %v", err)
+	}
+	if want := parseTestResourceRequirements("2G", limitCPU, "2G", limitCPU); !equalResources(got, want) {
+		t.Errorf("resolveResources() = %v, want %v", got, want)
+	}
+	if len(fixes) != 1 {
+		t.Errorf("resolveResources() fixes = %v, want the raised memory limit", fixes)
+	}
+}
@@ -0,0 +1,116 @@
+package webhook
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Values of Match.Pinned, how the version of an image is pinned.
+const (
+	pinnedDigest = "digest"
+	pinnedTag    = "tag"
+	pinnedNone   = "none"
+)
+
+// defaultRegistry is the registry of images without one, like nginx:1.25.
+const defaultRegistry = "docker.io"
+
+// imageRef is a container image reference split into its parts,
+// e.g. registry docker.io, repository library/nginx and tag 1.25 for nginx:1.25.
+type imageRef struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// parseImage splits image into its parts, normalized like docker does:
+// images without registry are on docker.io and official images there are in library/.
+func parseImage(image string) imageRef {
+	ref := imageRef{}
+	if i := strings.Index(image, "@"); i >= 0 {
+		image, ref.Digest = image[:i], image[i+1:]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image, ref.Tag = image[:i], image[i+1:]
+	}
+
+	ref.Registry, ref.Repository = defaultRegistry, image
+	if i := strings.Index(image, "/"); i >= 0 {
+		host := image[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			ref.Registry, ref.Repository = host, image[i+1:]
+		}
+	}
+	if ref.Registry == defaultRegistry && !strings.Contains(ref.Repository, "/") {
+		ref.Repository = "library/" + ref.Repository
+	}
+
+	return ref
+}
+
+// pinned returns how the version of the image is pinned: by digest, by tag (any but latest) or none.
+func (r imageRef) pinned() string {
+	switch {
+	case r.Digest != "":
+		return pinnedDigest
+	case r.Tag != "" && r.Tag != "latest":
+		return pinnedTag
+	}
+	return pinnedNone
+}
+
+// matchesImage reports whether image matches the image criteria of m.
+func (m Match) matchesImage(image string) bool {
+	if m.compiled == nil {
+		m.compiled, _ = m.compile()
+	}
+	if len(m.Images) > 0 && !matchesAny(m.compiled.images, image) {
+		return false
+	}
+	if len(m.ImageRegexps) > 0 && !matchesAny(m.compiled.imageRegexps, image) {
+		return false
+	}
+
+	ref := parseImage(image)
+	if len(m.Registries) > 0 && !matchesAny(m.compiled.registries, ref.Registry) {
+		return false
+	}
+	if len(m.Repositories) > 0 && !matchesAny(m.compiled.repositories, ref.Repository) {
+		return false
+	}
+	if len(m.Pinned) > 0 && !contains(m.Pinned, ref.pinned()) {
+		return false
+	}
+
+	return true
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validate checks that the regular expressions of m compile and its pinned values are known,
+// keeping the compiled patterns.
+func (m *Match) validate() error {
+	compiled, err := m.compile()
+	if err != nil {
+		return err
+	}
+	m.compiled = compiled
+	for _, p := range m.Pinned {
+		switch p {
+		case pinnedDigest, pinnedTag, pinnedNone:
+		default:
+			return fmt.Errorf("pinned %q is none of %s, %s, %s", p, pinnedDigest, pinnedTag, pinnedNone)
+		}
+	}
+
+	return nil
+}
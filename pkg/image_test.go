@@ -0,0 +1,94 @@
+package webhook
+
+import (
+	"testing"
+)
+
+func Test_parseImage(t *testing.T) {
+	tests := []struct {
+		image      string
+		want       imageRef
+		wantPinned string
+	}{
+		{image: "nginx", want: imageRef{Registry: "docker.io", Repository: "library/nginx"}, wantPinned: pinnedNone},
+		{image: "nginx:latest", want: imageRef{Registry: "docker.io", Repository: "library/nginx", Tag: "latest"}, wantPinned: pinnedNone},
+		{image: "bitnami/redis:7.2", want: imageRef{Registry: "docker.io", Repository: "bitnami/redis", Tag: "7.2"}, wantPinned: pinnedTag},
+		{image: "registry.internal/ml/trainer:1.0", want: imageRef{Registry: "registry.internal", Repository: "ml/trainer", Tag: "1.0"}, wantPinned: pinnedTag},
+		{image: "localhost:5000/app", want: imageRef{Registry: "localhost:5000", Repository: "app"}, wantPinned: pinnedNone},
+		{
+			image:      "ghcr.io/org/app:1.0@sha256:abc",
+			want:       imageRef{Registry: "ghcr.io", Repository: "org/app", Tag: "1.0", Digest: "sha256:abc"},
+			wantPinned: pinnedDigest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			got := parseImage(tt.image)
+			if got != tt.want {
+				t.Errorf("parseImage() = %+v, want %+v", got, tt.want)
+			}
+			if pinned := got.pinned(); pinned != tt.wantPinned {
+				t.Errorf("pinned() = %s, want %s", pinned, tt.wantPinned)
+			}
+		})
+	}
+}
+
+func TestMatch_matchesImage(t *testing.T) {
+	tests := []struct {
+		name  string
+		match Match
+		image string
+		want  bool
+	}{
+		{name: "no criteria", match: Match{}, image: "nginx", want: true},
+		{name: "glob", match: Match{Images: []string{"*/openjdk:*"}}, image: "eclipse/openjdk:17", want: true},
+		{name: "regexp", match: Match{ImageRegexps: []string{`(openjdk|temurin):(8|11|17)\b`}}, image: "eclipse-temurin:17-jre", want: true},
+		{name: "regexp mismatch", match: Match{ImageRegexps: []string{`^golang:`}}, image: "eclipse-temurin:17-jre", want: false},
+		{name: "registry", match: Match{Registries: []string{"*.internal"}}, image: "registry.internal/ml/trainer:1.0", want: true},
+		{name: "default registry", match: Match{Registries: []string{"docker.io"}}, image: "nginx", want: true},
+		{name: "repository", match: Match{Repositories: []string{"ml/*"}}, image: "registry.internal/ml/trainer:1.0", want: true},
+		{name: "repository of official image", match: Match{Repositories: []string{"library/*"}}, image: "registry.internal/ml/trainer", want: false},
+		{name: "pinned by digest", match: Match{Pinned: []string{pinnedDigest}}, image: "nginx@sha256:abc", want: true},
+		{name: "unpinned", match: Match{Pinned: []string{pinnedNone}}, image: "nginx:latest", want: true},
+		{name: "not pinned by tag", match: Match{Pinned: []string{pinnedTag}}, image: "nginx", want: false},
+		{
+			name:  "all criteria",
+			match: Match{Registries: []string{"registry.internal"}, Repositories: []string{"ml/*"}, Pinned: []string{pinnedTag, pinnedDigest}},
+			image: "registry.internal/ml/trainer:1.0",
+			want:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.match.matchesImage(tt.image); got != tt.want {
+				t.Errorf("matchesImage() = %v, want %v", got, tt.want)
+			}
+			if got := testCompiledMatch(tt.match).matchesImage(tt.image); got != tt.want {
+				t.Errorf("matchesImage() of the compiled match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatch_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		match   Match
+		wantErr bool
+	}{
+		{name: "valid", match: Match{ImageRegexps: []string{`^openjdk:`}, Pinned: []string{pinnedNone}}},
+		{name: "invalid regexp", match: Match{ImageRegexps: []string{`(openjdk`}}, wantErr: true},
+		{name: "unknown pinned", match: Match{Pinned: []string{"sha"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.match.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && tt.match.compiled == nil {
+				t.Errorf("validate() didn't keep the compiled patterns")
+			}
+		})
+	}
+}
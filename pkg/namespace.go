@@ -0,0 +1,135 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	k8s_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// annotationPrefix prefixes all annotations the webhook reads or writes.
+const annotationPrefix = "default-resources.io/"
+
+// namespaceAnnotations are the annotations of a namespace overriding the container defaults for pods in that namespace.
+var namespaceAnnotations = []struct {
+	annotation string
+	limit      bool
+	name       k8s_v1.ResourceName
+}{
+	{annotation: annotationPrefix + "limit-memory", limit: true, name: k8s_v1.ResourceMemory},
+	{annotation: annotationPrefix + "limit-cpu", limit: true, name: k8s_v1.ResourceCPU},
+	{annotation: annotationPrefix + "request-memory", limit: false, name: k8s_v1.ResourceMemory},
+	{annotation: annotationPrefix + "request-cpu", limit: false, name: k8s_v1.ResourceCPU},
+}
+
+// namespaceGetTimeout bounds the live lookup of a namespace missing in the cache.
+const namespaceGetTimeout = 2 * time.Second
+
+// NewNamespaceLister starts an informer caching all namespaces until stop is closed
+// and returns a lister backed by it, once the cache is synced.
+// Namespaces missing in the cache are looked up through client, since the informer may not have seen
+// a namespace created right before its first pod yet.
+func NewNamespaceLister(client corev1client.CoreV1Interface, resync time.Duration, stop <-chan struct{}) (corev1listers.NamespaceLister, error) {
+	informer := cache.NewSharedIndexInformer(
+		cache.NewListWatchFromClient(client.RESTClient(), "namespaces", k8s_v1.NamespaceAll, fields.Everything()),
+		&k8s_v1.Namespace{},
+		resync,
+		cache.Indexers{},
+	)
+	go informer.Run(stop)
+
+	if !cache.WaitForCacheSync(stop, informer.HasSynced) {
+		return nil, fmt.Errorf("failed to sync namespace cache")
+	}
+
+	return liveNamespaceLister{NamespaceLister: corev1listers.NewNamespaceLister(informer.GetIndexer()), client: client}, nil
+}
+
+// liveNamespaceLister gets the namespaces its NamespaceLister hasn't cached from the api-server.
+type liveNamespaceLister struct {
+	corev1listers.NamespaceLister
+	client corev1client.NamespacesGetter
+}
+
+// Get implements corev1listers.NamespaceLister.
+func (l liveNamespaceLister) Get(name string) (*k8s_v1.Namespace, error) {
+	ns, err := l.NamespaceLister.Get(name)
+	if !errors.IsNotFound(err) {
+		return ns, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), namespaceGetTimeout)
+	defer cancel()
+	return l.client.Namespaces().Get(ctx, name, metav1.GetOptions{})
+}
+
+// getNamespace returns the namespace name from lister,
+// nil for cluster scoped objects, a nil lister or if the namespace can't be found.
+func getNamespace(lister corev1listers.NamespaceLister, name string) *k8s_v1.Namespace {
+	if lister == nil || name == "" {
+		return nil
+	}
+	ns, err := lister.Get(name)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"namespace": name,
+		}).Warnf("failed to get namespace: %s", err)
+		return nil
+	}
+
+	return ns
+}
+
+// namespaceOverrides parses the resource annotations of a namespace.
+// Annotations with invalid quantities are skipped and reported as warnings.
+func namespaceOverrides(ns *k8s_v1.Namespace) (k8s_v1.ResourceRequirements, []string) {
+	o := k8s_v1.ResourceRequirements{Limits: k8s_v1.ResourceList{}, Requests: k8s_v1.ResourceList{}}
+	warnings := []string{}
+	for _, a := range namespaceAnnotations {
+		value, found := ns.Annotations[a.annotation]
+		if !found {
+			continue
+		}
+		q, err := resource.ParseQuantity(value)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("default-container-resources: ignored annotation %s of namespace %s: %s", a.annotation, ns.Name, err))
+			continue
+		}
+
+		if a.limit {
+			o.Limits[a.name] = q
+		} else {
+			o.Requests[a.name] = q
+		}
+	}
+
+	return o, warnings
+}
+
+// overrideDefaults returns d with every value set in o replaced.
+func overrideDefaults(d k8s_v1.ResourceRequirements, o k8s_v1.ResourceRequirements) k8s_v1.ResourceRequirements {
+	d = *d.DeepCopy()
+	for name, q := range o.Limits {
+		if d.Limits == nil {
+			d.Limits = k8s_v1.ResourceList{}
+		}
+		d.Limits[name] = q
+	}
+	for name, q := range o.Requests {
+		if d.Requests == nil {
+			d.Requests = k8s_v1.ResourceList{}
+		}
+		d.Requests[name] = q
+	}
+
+	return d
+}
@@ -0,0 +1,154 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	k8s_v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+func testNamespace(name string, annotations map[string]string) *k8s_v1.Namespace {
+	return &k8s_v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations}}
+}
+
+func testNamespaceLister(namespaces ...*k8s_v1.Namespace) corev1listers.NamespaceLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, ns := range namespaces {
+		if err := indexer.Add(ns); err != nil {
+			panic(err)
+		}
+	}
+	return corev1listers.NewNamespaceLister(indexer)
+}
+
+func Test_namespaceOverrides(t *testing.T) {
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		want         k8s_v1.ResourceRequirements
+		wantWarnings int
+	}{
+		{
+			name: "no annotations keeps defaults",
+			want: defaults,
+		},
+		{
+			name: "annotations override defaults",
+			annotations: map[string]string{
+				"default-resources.io/limit-memory":   "4Gi",
+				"default-resources.io/request-memory": "2Gi",
+				"default-resources.io/limit-cpu":      "2",
+				"default-resources.io/request-cpu":    "1",
+				"other-annotation":                    "foo",
+			},
+			want: parseTestResourceRequirements("4Gi", "2", "2Gi", "1"),
+		},
+		{
+			name: "invalid annotation is ignored with warning",
+			annotations: map[string]string{
+				"default-resources.io/limit-memory":   "lots",
+				"default-resources.io/request-memory": "256Mi",
+			},
+			want:         parseTestResourceRequirements(limitMemory, limitCPU, "256Mi", requestCPU),
+			wantWarnings: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			overrides, warnings := namespaceOverrides(testNamespace("foo", tt.annotations))
+			got := overrideDefaults(defaults, overrides)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("overrideDefaults() = %v, want %v", got, tt.want)
+			}
+			if len(warnings) != tt.wantWarnings {
+				t.Errorf("namespaceOverrides() warnings = %v, want %d", warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestMutator_Mutate_namespaceOverrides(t *testing.T) {
+	m := &Mutator{
+		Policy:   Policy{Default: Defaults{Containers: defaults}},
+		Strategy: ComplementToDefault{},
+		Namespaces: testNamespaceLister(
+			testNamespace("data-science", map[string]string{"default-resources.io/limit-memory": "4Gi"}),
+		),
+	}
+
+	tests := []struct {
+		namespace string
+		want      string
+	}{
+		{namespace: "data-science", want: "4Gi"},
+		{namespace: "unknown", want: limitMemory},
+	}
+	for _, tt := range tests {
+		t.Run(tt.namespace, func(t *testing.T) {
+			pod := `{"spec":{"containers":[{"name":"nginx"}]}}`
+			body := `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","request":{"uid":"1","kind":{"group":"","version":"v1","kind":"Pod"},"namespace":"` + tt.namespace + `","operation":"CREATE","object":` + pod + `}}`
+			w := httptest.NewRecorder()
+			if err := m.Mutate(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))); err != nil {
+				t.Fatalf("Mutate() error = %v", err)
+			}
+
+			resources := decodeTestResponsePatch(t, pod, w.Body.Bytes())["/spec/containers/0/resources"]
+			got := resources.Limits[k8s_v1.ResourceMemory]
+			if got.Cmp(getResourceQuantity(tt.want)) != 0 {
+				t.Errorf("Mutate() limits.memory = %s, want %s", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_liveNamespaceLister(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/new" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		ns := testNamespace("new", map[string]string{"default-resources.io/limit-memory": "4Gi"})
+		ns.APIVersion, ns.Kind = "v1", "Namespace"
+		if err := json.NewEncoder(w).Encode(ns); err != nil {
+			t.Errorf("failed to encode namespace: %s", err)
+		}
+	}))
+	defer server.Close()
+	client, err := corev1client.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatalf("NewForConfig() error = %v", err)
+	}
+	lister := liveNamespaceLister{NamespaceLister: testNamespaceLister(testNamespace("cached", nil)), client: client}
+
+	tests := []struct {
+		name            string
+		namespace       string
+		wantAnnotations int
+		wantNil         bool
+	}{
+		{name: "cached namespace", namespace: "cached"},
+		{name: "namespace missing in the cache", namespace: "new", wantAnnotations: 1},
+		{name: "unknown namespace", namespace: "unknown", wantNil: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getNamespace(lister, tt.namespace)
+			if (got == nil) != tt.wantNil {
+				t.Fatalf("getNamespace() = %v, want nil %v", got, tt.wantNil)
+			}
+			if got != nil && (got.Name != tt.namespace || len(got.Annotations) != tt.wantAnnotations) {
+				t.Errorf("getNamespace() = %v, want %s with %d annotations", got, tt.namespace, tt.wantAnnotations)
+			}
+		})
+	}
+}
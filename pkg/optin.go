@@ -0,0 +1,34 @@
+package webhook
+
+import (
+	"fmt"
+
+	k8s_v1 "k8s.io/api/core/v1"
+)
+
+// optInLabel is the namespace label selecting the profile for the pods of the namespace in opt-in mode,
+// e.g. default-resources-webhook: small. It's the label the namespaceSelector of the
+// MutatingWebhookConfiguration opts namespaces out with (default-resources-webhook: disabled).
+const optInLabel = "default-resources-webhook"
+
+// optIn returns the policy for pods in the namespace ns in opt-in mode: the profile selected by the label of ns
+// replaces the default of p. It reports false for namespaces without the label (or a nil ns), whose pods are left alone,
+// together with a warning if the label selects an unknown profile.
+func (p Policy) optIn(ns *k8s_v1.Namespace) (Policy, bool, string) {
+	if ns == nil {
+		return p, false, ""
+	}
+	name, found := ns.Labels[optInLabel]
+	if !found || name == "disabled" {
+		return p, false, ""
+	}
+
+	profile, found := p.Profiles[name]
+	if !found {
+		return p, false, fmt.Sprintf("default-container-resources: label %s of namespace %s selects unknown profile %q, resources are not defaulted", optInLabel, ns.Name, name)
+	}
+	p.Default = profile
+	p.DefaultRule = profileRulePrefix + name
+
+	return p, true, ""
+}
@@ -0,0 +1,91 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	admission_v1 "k8s.io/api/admission/v1"
+	k8s_v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMutate_optIn(t *testing.T) {
+	medium := parseTestResourceRequirements("2G", "1", "1G", "500m")
+	labeled := func(name, profile string) *k8s_v1.Namespace {
+		return &k8s_v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{optInLabel: profile}}}
+	}
+	m := &Mutator{
+		Policy: Policy{
+			Default:  Defaults{Containers: defaults},
+			Profiles: map[string]Defaults{"medium": {Containers: medium}},
+		},
+		Strategy: ComplementToDefault{},
+		ProfileNamespaces: testNamespaceLister(
+			labeled("team-a", "medium"),
+			labeled("team-b", "huge"),
+			labeled("team-c", "disabled"),
+			testNamespace("team-d", nil),
+		),
+	}
+
+	tests := []struct {
+		namespace    string
+		want         map[string]k8s_v1.ResourceRequirements
+		wantWarnings int
+	}{
+		{
+			namespace: "team-a",
+			want:      map[string]k8s_v1.ResourceRequirements{"/spec/containers/0/resources": medium},
+			// the defaulted warning
+			wantWarnings: 1,
+		},
+		{namespace: "team-b", want: map[string]k8s_v1.ResourceRequirements{}, wantWarnings: 1},
+		{namespace: "team-c", want: map[string]k8s_v1.ResourceRequirements{}},
+		{namespace: "team-d", want: map[string]k8s_v1.ResourceRequirements{}},
+		{namespace: "unknown", want: map[string]k8s_v1.ResourceRequirements{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.namespace, func(t *testing.T) {
+			pod := `{"metadata":{"name":"nginx"},"spec":{"containers":[{"name":"nginx"}]}}`
+			body := `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","request":{"uid":"1","kind":{"group":"","version":"v1","kind":"Pod"},"namespace":"` + tt.namespace + `","operation":"CREATE","object":` + pod + `}}`
+			w := httptest.NewRecorder()
+			if err := m.Mutate(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))); err != nil {
+				t.Fatalf("Mutate() error = %v", err)
+			}
+
+			out := admission_v1.AdmissionReview{}
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if !out.Response.Allowed {
+				t.Fatalf("Mutate() denied: %v", out.Response.Result)
+			}
+			if len(out.Response.Warnings) != tt.wantWarnings {
+				t.Errorf("Mutate() warnings = %v, want %d", out.Response.Warnings, tt.wantWarnings)
+			}
+			got := decodeTestResponsePatch(t, pod, w.Body.Bytes())
+			if len(got) != len(tt.want) {
+				t.Errorf("Mutate() patched %v, want %v", got, tt.want)
+			}
+			for path, want := range tt.want {
+				if !equalResources(got[path], want) {
+					t.Errorf("Mutate() %s = %v, want %v", path, got[path], want)
+				}
+			}
+		})
+	}
+}
+
+func TestPolicy_optIn(t *testing.T) {
+	p := Policy{Profiles: map[string]Defaults{"small": {Containers: defaults}}}
+	p, optedIn, _ := p.optIn(&k8s_v1.Namespace{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{optInLabel: "small"}}})
+	if !optedIn {
+		t.Fatal("optIn() = false, want true")
+	}
+	if rule, _ := p.defaultsFor("foo", nil, k8s_v1.Container{Name: "app"}); rule != "Profile/small" {
+		t.Errorf("defaultsFor() rule = %q, want Profile/small", rule)
+	}
+}
@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"strconv"
+	"strings"
+
+	k8s_v1 "k8s.io/api/core/v1"
+)
+
+// containerPatches returns the patches changing the resources of the container c at index i of the containers
+// of kind (e.g. initContainers) in s to r, nothing if they are equal. The patches test the name of the container
+// at the index first, so they get refused if another webhook reordered the containers in the meantime.
+func containerPatches(s podSpec, kind string, i int, c k8s_v1.Container, r k8s_v1.ResourceRequirements) []Patch {
+	containerPath := s.Path + "/" + kind + "/" + strconv.Itoa(i)
+	patches := resourcesPatches(containerPath+"/resources", c.Resources, r)
+	if len(patches) == 0 {
+		return nil
+	}
+	patches = append([]Patch{{Op: "test", Path: containerPath + "/name", Value: c.Name}}, patches...)
+
+	// test the values as written in the object, a test of a quantity in another format fails
+	for i, p := range patches {
+		if p.Op != "test" || s.Raw == nil {
+			continue
+		}
+		if v, found := lookupPointer(s.Raw, strings.TrimPrefix(p.Path, s.Path)); found {
+			patches[i].Value = v
+		}
+	}
+
+	return patches
+}
+
+// resourcesPatches returns the patches changing the resources old at path to new, nothing if they are equal.
+// Only changed quantities are patched, so the ones set by the user keep their formatting.
+// The old quantities are tested to still have their value, since the new ones are computed from them.
+func resourcesPatches(path string, old, new k8s_v1.ResourceRequirements) []Patch {
+	if len(old.Limits) == 0 && len(old.Requests) == 0 && len(old.Claims) == 0 {
+		// resources may be missing in the object, so add it as a whole
+		if len(new.Limits) == 0 && len(new.Requests) == 0 {
+			return nil
+		}
+		return []Patch{{Op: "add", Path: path, Value: new}}
+	}
+
+	limitTests, limits := resourceListPatches(path+"/limits", old.Limits, new.Limits)
+	requestTests, requests := resourceListPatches(path+"/requests", old.Requests, new.Requests)
+	if len(limits) == 0 && len(requests) == 0 {
+		return nil
+	}
+
+	patches := append(limitTests, requestTests...)
+	patches = append(patches, limits...)
+	return append(patches, requests...)
+}
+
+// resourceListPatches returns the patches changing the resource list old at path to new,
+// together with the patches testing the old quantities.
+func resourceListPatches(path string, old, new k8s_v1.ResourceList) ([]Patch, []Patch) {
+	var tests, patches []Patch
+	if len(old) == 0 {
+		if len(new) > 0 {
+			patches = append(patches, Patch{Op: "add", Path: path, Value: new})
+		}
+		return nil, patches
+	}
+
+	for _, name := range sortedResourceNames(old) {
+		tests = append(tests, Patch{Op: "test", Path: path + "/" + escapePointerToken(string(name)), Value: old[name]})
+	}
+	for _, name := range sortedResourceNames(new) {
+		q := new[name]
+		if o, found := old[name]; found && o.Cmp(q) == 0 {
+			continue
+		}
+		// add replaces an existing quantity
+		patches = append(patches, Patch{Op: "add", Path: path + "/" + escapePointerToken(string(name)), Value: q})
+	}
+	for _, name := range sortedResourceNames(old) {
+		if _, found := new[name]; !found {
+			patches = append(patches, Patch{Op: "remove", Path: path + "/" + escapePointerToken(string(name))})
+		}
+	}
+
+	return tests, patches
+}
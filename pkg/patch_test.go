@@ -0,0 +1,156 @@
+package webhook
+
+import (
+	"reflect"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	k8s_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func Test_resourcesPatches(t *testing.T) {
+	gpu := k8s_v1.ResourceName("nvidia.com/gpu")
+	tests := []struct {
+		name string
+		old  k8s_v1.ResourceRequirements
+		new  k8s_v1.ResourceRequirements
+		want []Patch
+	}{
+		{
+			name: "unchanged resources",
+			old:  parseTestResourceRequirements("1Gi", "500m", "", ""),
+			new:  parseTestResourceRequirements("1024Mi", "0.5", "", ""),
+			want: nil,
+		},
+		{
+			name: "empty resources are added as a whole",
+			old:  k8s_v1.ResourceRequirements{},
+			new:  parseTestResourceRequirements("", "", "", "100m"),
+			want: []Patch{{Op: "add", Path: "/r", Value: parseTestResourceRequirements("", "", "", "100m")}},
+		},
+		{
+			name: "missing list is added as a whole",
+			old:  parseTestResourceRequirements("1Gi", "", "", ""),
+			new:  parseTestResourceRequirements("1Gi", "", "", "100m"),
+			want: []Patch{
+				{Op: "test", Path: "/r/limits/memory", Value: getResourceQuantity("1Gi")},
+				{Op: "add", Path: "/r/requests", Value: parseTestResourceRequirements("", "", "", "100m").Requests},
+			},
+		},
+		{
+			name: "only changed quantities are added",
+			old:  parseTestResourceRequirements("1Gi", "", "", ""),
+			new:  parseTestResourceRequirements("1Gi", "2", "", ""),
+			want: []Patch{
+				{Op: "test", Path: "/r/limits/memory", Value: getResourceQuantity("1Gi")},
+				{Op: "add", Path: "/r/limits/cpu", Value: getResourceQuantity("2")},
+			},
+		},
+		{
+			name: "resource names are escaped",
+			old:  k8s_v1.ResourceRequirements{Limits: k8s_v1.ResourceList{gpu: resource.MustParse("1")}},
+			new:  k8s_v1.ResourceRequirements{Limits: k8s_v1.ResourceList{gpu: resource.MustParse("2")}},
+			want: []Patch{
+				{Op: "test", Path: "/r/limits/nvidia.com~1gpu", Value: getResourceQuantity("1")},
+				{Op: "add", Path: "/r/limits/nvidia.com~1gpu", Value: getResourceQuantity("2")},
+			},
+		},
+		{
+			name: "stripped quantities are removed",
+			old:  parseTestResourceRequirements("1Gi", "2", "", ""),
+			new:  parseTestResourceRequirements("1Gi", "", "", ""),
+			want: []Patch{
+				{Op: "test", Path: "/r/limits/cpu", Value: getResourceQuantity("2")},
+				{Op: "test", Path: "/r/limits/memory", Value: getResourceQuantity("1Gi")},
+				{Op: "remove", Path: "/r/limits/cpu"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resourcesPatches("/r", tt.old, tt.new); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resourcesPatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_createResponse_noPatch(t *testing.T) {
+	spec := k8s_v1.PodSpec{Containers: []k8s_v1.Container{{Name: "app", Resources: defaults}}}
+	resp, _, err := createResponse([]podSpec{{Path: "/spec", Spec: spec}}, staticDefaults(Defaults{Containers: defaults}), ComplementToDefault{}, appliedMarker{})
+	if err != nil {
+		t.Fatalf("createResponse() error = %v", err)
+	}
+	if !resp.Allowed || resp.Patch != nil || len(resp.Warnings) != 0 {
+		t.Errorf("createResponse() = %+v, want allowed without patch", resp)
+	}
+}
+
+func Test_createResponse_stalePatch(t *testing.T) {
+	s := podSpec{Path: "/spec", Spec: k8s_v1.PodSpec{Containers: []k8s_v1.Container{
+		{Name: "app", Resources: parseTestResourceRequirements("", "2", "", "")},
+	}}}
+	resp, _, err := createResponse([]podSpec{s}, staticDefaults(Defaults{Containers: defaults}), ComplementToDefault{}, appliedMarker{})
+	if err != nil {
+		t.Fatalf("createResponse() error = %v", err)
+	}
+	patch, err := jsonpatch.DecodePatch(resp.Patch)
+	if err != nil {
+		t.Fatalf("failed to decode patch: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		containers []k8s_v1.Container
+		wantErr    bool
+	}{
+		{
+			name:       "unchanged containers",
+			containers: s.Spec.Containers,
+		},
+		{
+			name: "container injected at the index",
+			containers: []k8s_v1.Container{
+				{Name: "proxy", Resources: parseTestResourceRequirements("", "2", "", "")},
+				s.Spec.Containers[0],
+			},
+			wantErr: true,
+		},
+		{
+			name: "resources changed in the meantime",
+			containers: []k8s_v1.Container{
+				{Name: "app", Resources: parseTestResourceRequirements("", "4", "", "")},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := testObject(t, podSpec{Path: "/spec", Spec: k8s_v1.PodSpec{Containers: tt.containers}})
+			if _, err := patch.Apply(doc); (err != nil) != tt.wantErr {
+				t.Errorf("Apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_createResponse_rawQuantities(t *testing.T) {
+	// custom resources keep quantities as written
+	raw := []byte(`{"spec":{"template":{"spec":{"containers":[{"name":"app","resources":{"limits":{"cpu":0.5}}}]}}}}`)
+	gvk := schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"}
+	specs, err := findPodSpecs(gvk, raw, []PodSpecPath{{Group: "argoproj.io", Kind: "Rollout", Paths: []string{"/spec/template/spec"}}})
+	if err != nil {
+		t.Fatalf("findPodSpecs() error = %v", err)
+	}
+
+	resp, _, err := createResponse(specs, staticDefaults(Defaults{Containers: defaults}), ComplementToDefault{}, appliedMarker{})
+	if err != nil {
+		t.Fatalf("createResponse() error = %v", err)
+	}
+	got := patchedTestResources(t, "/spec/template/spec", raw, applyTestPatch(t, raw, resp.Patch))
+	if want := defaults; !equalResources(got["/spec/template/spec/containers/0/resources"], want) {
+		t.Errorf("createResponse() patched %v, want %v", got, want)
+	}
+}
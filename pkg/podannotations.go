@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	k8s_v1 "k8s.io/api/core/v1"
+)
+
+// Annotations of pods (and pod templates) controlling the webhook if the policy allows them:
+// skip: "true" leaves the pod alone, skip-containers lists the comma separated names of containers to leave alone
+// and profile.<container> selects the named profile of the policy for a container, e.g. profile.nginx: large.
+// Skipped containers still have to meet the bounds, ratios and QoS of their defaults.
+const (
+	skipAnnotation           = annotationPrefix + "skip"
+	skipContainersAnnotation = annotationPrefix + "skip-containers"
+	profileAnnotationPrefix  = annotationPrefix + "profile."
+)
+
+// profileRulePrefix prefixes the profile names reported as rule, e.g. Profile/large.
+const profileRulePrefix = "Profile/"
+
+// skipsPod reports whether the annotations of s opt the pod out of the webhook.
+func skipsPod(s podSpec) bool {
+	skip, _ := strconv.ParseBool(s.Meta.Annotations[skipAnnotation])
+	return skip
+}
+
+// skippedContainers returns the names of the containers of s opted out of the webhook.
+func skippedContainers(s podSpec) map[string]bool {
+	skipped := map[string]bool{}
+	for _, name := range strings.Split(s.Meta.Annotations[skipContainersAnnotation], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			skipped[name] = true
+		}
+	}
+
+	return skipped
+}
+
+// skips reports whether the annotations of s opt the container c out of defaulting.
+func (p Policy) skips(s podSpec, c k8s_v1.Container) bool {
+	return p.AllowPodAnnotations && (skipsPod(s) || skippedContainers(s)[c.Name])
+}
+
+// defaultsForPod returns the defaults and the name of the rule providing them for container c of the PodSpec s
+// in namespace. A profile selected by the annotations of s takes precedence over the rules.
+func (p Policy) defaultsForPod(namespace string, s podSpec, c k8s_v1.Container) (string, Defaults, error) {
+	name, found := s.Meta.Annotations[profileAnnotationPrefix+c.Name]
+	if !found || !p.AllowPodAnnotations {
+		rule, d := p.defaultsFor(namespace, s.Meta.Labels, c)
+		return rule, d, nil
+	}
+
+	profile, found := p.Profiles[name]
+	if !found {
+		return "", Defaults{}, fmt.Errorf("unknown profile %q", name)
+	}
+
+	return profileRulePrefix + name, profile, nil
+}
+
+// checkConstraints checks the resources r of a skipped container against the bounds, ratios and QoS of d.
+// Skipped containers aren't changed, so violations are denied even if d would clamp or raise them.
+func (d Defaults) checkConstraints(r k8s_v1.ResourceRequirements) error {
+	d.Clamp, d.RaiseRequests = false, false
+	if _, err := d.enforceBounds(r); err != nil {
+		return err
+	}
+	if _, err := d.enforceLimitRequestRatio(r); err != nil {
+		return err
+	}
+	if d.QoS != qosGuaranteed {
+		return nil
+	}
+
+	guaranteed, err := guarantee(r)
+	if err != nil {
+		return err
+	}
+	for _, name := range []k8s_v1.ResourceName{k8s_v1.ResourceMemory, k8s_v1.ResourceCPU} {
+		// a missing request defaults to the limit
+		if request, found := r.Requests[name]; found && request.Cmp(guaranteed.Requests[name]) != 0 {
+			return fmt.Errorf("can't get Guaranteed QoS with requests.%s %s below its limit", name, request.String())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	admission_v1 "k8s.io/api/admission/v1"
+	k8s_v1 "k8s.io/api/core/v1"
+)
+
+func TestMutate_podAnnotations(t *testing.T) {
+	large := parseTestResourceRequirements("4G", "2", "2G", "1")
+	m := &Mutator{
+		Policy: Policy{
+			Default:             Defaults{Containers: defaults},
+			Profiles:            map[string]Defaults{"large": {Containers: large}},
+			AllowPodAnnotations: true,
+		},
+		Strategy: ComplementToDefault{},
+	}
+
+	tests := []struct {
+		name        string
+		annotations string
+		wantAllowed bool
+		want        map[string]k8s_v1.ResourceRequirements
+	}{
+		{
+			name:        "no annotations",
+			annotations: `{}`,
+			wantAllowed: true,
+			want: map[string]k8s_v1.ResourceRequirements{
+				"/spec/containers/0/resources": defaults,
+				"/spec/containers/1/resources": defaults,
+			},
+		},
+		{
+			name:        "skipped pod",
+			annotations: `{"default-resources.io/skip":"true"}`,
+			wantAllowed: true,
+			want:        map[string]k8s_v1.ResourceRequirements{},
+		},
+		{
+			name:        "skipped container",
+			annotations: `{"default-resources.io/skip-containers":"proxy, debug"}`,
+			wantAllowed: true,
+			want: map[string]k8s_v1.ResourceRequirements{
+				"/spec/containers/0/resources": defaults,
+			},
+		},
+		{
+			name:        "profile of a container",
+			annotations: `{"default-resources.io/profile.nginx":"large"}`,
+			wantAllowed: true,
+			want: map[string]k8s_v1.ResourceRequirements{
+				"/spec/containers/0/resources": large,
+				"/spec/containers/1/resources": defaults,
+			},
+		},
+		{
+			name:        "unknown profile is denied",
+			annotations: `{"default-resources.io/profile.nginx":"huge"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := `{"metadata":{"annotations":` + tt.annotations + `},"spec":{"containers":[{"name":"nginx"},{"name":"proxy"}]}}`
+			body := `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","request":{"uid":"1","kind":{"group":"","version":"v1","kind":"Pod"},"namespace":"foo","operation":"CREATE","object":` + pod + `}}`
+			w := httptest.NewRecorder()
+			if err := m.Mutate(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))); err != nil {
+				t.Fatalf("Mutate() error = %v", err)
+			}
+
+			out := admission_v1.AdmissionReview{}
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if out.Response.Allowed != tt.wantAllowed {
+				t.Fatalf("Mutate() Allowed = %v, want %v: %v", out.Response.Allowed, tt.wantAllowed, out.Response.Result)
+			}
+			if !tt.wantAllowed {
+				return
+			}
+			got := decodeTestResponsePatch(t, pod, w.Body.Bytes())
+			if len(got) != len(tt.want) {
+				t.Errorf("Mutate() patched %v, want %v", got, tt.want)
+			}
+			for path, want := range tt.want {
+				if !equalResources(got[path], want) {
+					t.Errorf("Mutate() %s = %v, want %v", path, got[path], want)
+				}
+			}
+		})
+	}
+}
+
+func TestMutate_podAnnotationsConstraints(t *testing.T) {
+	max := Defaults{Containers: defaults, Max: k8s_v1.ResourceList{k8s_v1.ResourceMemory: getResourceQuantity("2G")}}
+
+	tests := []struct {
+		name        string
+		policy      Policy
+		annotations string
+		limits      string
+		wantAllowed bool
+		wantPatched int
+	}{
+		{
+			name:        "skipped container within the bounds",
+			policy:      Policy{Default: max, AllowPodAnnotations: true},
+			annotations: `{"default-resources.io/skip-containers":"proxy"}`,
+			limits:      `{"memory":"1G"}`,
+			wantAllowed: true,
+			wantPatched: 1,
+		},
+		{
+			name:        "skipped container above the maximum is denied",
+			policy:      Policy{Default: max, AllowPodAnnotations: true},
+			annotations: `{"default-resources.io/skip-containers":"proxy"}`,
+			limits:      `{"memory":"4G"}`,
+		},
+		{
+			name:        "skipped pod above the maximum is denied",
+			policy:      Policy{Default: max, AllowPodAnnotations: true},
+			annotations: `{"default-resources.io/skip":"true"}`,
+			limits:      `{"memory":"4G"}`,
+		},
+		{
+			name:        "skipped container without Guaranteed QoS is denied",
+			policy:      Policy{Default: Defaults{Containers: defaults, QoS: qosGuaranteed}, AllowPodAnnotations: true},
+			annotations: `{"default-resources.io/skip-containers":"proxy"}`,
+			limits:      `{"memory":"1G"}`,
+		},
+		{
+			name:        "annotations are ignored unless allowed",
+			policy:      Policy{Default: Defaults{Containers: defaults}},
+			annotations: `{"default-resources.io/skip":"true","default-resources.io/profile.nginx":"huge"}`,
+			limits:      `{"memory":"1G"}`,
+			wantAllowed: true,
+			wantPatched: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Mutator{Policy: tt.policy, Strategy: ComplementToDefault{}}
+			pod := `{"metadata":{"annotations":` + tt.annotations + `},"spec":{"containers":[{"name":"nginx"},{"name":"proxy","resources":{"limits":` + tt.limits + `}}]}}`
+			body := `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","request":{"uid":"1","kind":{"group":"","version":"v1","kind":"Pod"},"namespace":"foo","operation":"CREATE","object":` + pod + `}}`
+			w := httptest.NewRecorder()
+			if err := m.Mutate(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))); err != nil {
+				t.Fatalf("Mutate() error = %v", err)
+			}
+
+			out := admission_v1.AdmissionReview{}
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if out.Response.Allowed != tt.wantAllowed {
+				t.Fatalf("Mutate() Allowed = %v, want %v: %v", out.Response.Allowed, tt.wantAllowed, out.Response.Result)
+			}
+			if !tt.wantAllowed {
+				if !strings.Contains(out.Response.Result.Message, `container "proxy"`) {
+					t.Errorf("Mutate() denied with %q, want a denial of the proxy", out.Response.Result.Message)
+				}
+				return
+			}
+			if got := decodeTestResponsePatch(t, pod, w.Body.Bytes()); len(got) != tt.wantPatched {
+				t.Errorf("Mutate() patched %v, want %d containers", got, tt.wantPatched)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	k8s_v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// podSpecPaths maps the kinds the webhook mutates to the JSON pointers of the PodSpecs they embed.
+var podSpecPaths = map[schema.GroupKind][]string{
+	{Group: "", Kind: "Pod"}:             {"/spec"},
+	{Group: "apps", Kind: "Deployment"}:  {"/spec/template/spec"},
+	{Group: "apps", Kind: "StatefulSet"}: {"/spec/template/spec"},
+	{Group: "apps", Kind: "DaemonSet"}:   {"/spec/template/spec"},
+	{Group: "apps", Kind: "ReplicaSet"}:  {"/spec/template/spec"},
+	{Group: "batch", Kind: "Job"}:        {"/spec/template/spec"},
+	{Group: "batch", Kind: "CronJob"}:    {"/spec/jobTemplate/spec/template/spec"},
+}
+
+// templateOwners maps the kinds to the kinds of controllers creating them from a pod template of their own.
+// The webhook already defaulted that template, so the objects they create are left alone.
+var templateOwners = map[schema.GroupKind]schema.GroupKind{
+	{Group: "apps", Kind: "ReplicaSet"}: {Group: "apps", Kind: "Deployment"},
+	{Group: "batch", Kind: "Job"}:       {Group: "batch", Kind: "CronJob"},
+}
+
+// ownedByTemplateOwner reports whether raw, an object of kind gvk, was created by one of its templateOwners.
+func ownedByTemplateOwner(gvk schema.GroupVersionKind, raw []byte) (bool, error) {
+	owner, found := templateOwners[gvk.GroupKind()]
+	if !found {
+		return false, nil
+	}
+
+	obj := metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return false, fmt.Errorf("failed to Unmarshal metadata of %s: %s", gvk, err)
+	}
+	for _, ref := range obj.OwnerReferences {
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err != nil {
+			continue
+		}
+		if gv.WithKind(ref.Kind).GroupKind() == owner {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// podSpecPathsFor returns the JSON pointers of the PodSpecs embedded in objects of kind gvk.
+// Configured paths take precedence over the built-in ones.
+func podSpecPathsFor(gvk schema.GroupVersionKind, configured []PodSpecPath) []string {
+	for _, p := range configured {
+		if p.Group == gvk.Group && p.Kind == gvk.Kind && (p.Version == "" || p.Version == gvk.Version) {
+			return p.Paths
+		}
+	}
+
+	return podSpecPaths[gvk.GroupKind()]
+}
+
+// podSpec is a PodSpec found at Path within the admitted object.
+type podSpec struct {
+	Path string
+	Spec k8s_v1.PodSpec
+	// Meta is the metadata next to the PodSpec, e.g. of the pod template.
+	Meta metav1.ObjectMeta
+	// NoMeta is set if the object has no metadata next to the PodSpec.
+	NoMeta bool
+	// Raw is the PodSpec as decoded from the JSON of the object, nil if the PodSpec wasn't decoded from JSON.
+	// Unlike Spec it keeps quantities as written, which custom resources don't normalize.
+	Raw interface{}
+}
+
+// findPodSpecs decodes the PodSpecs embedded in raw, an object of kind gvk.
+// Unknown kinds and PodSpecs missing in the object are skipped.
+func findPodSpecs(gvk schema.GroupVersionKind, raw []byte, configured []PodSpecPath) ([]podSpec, error) {
+	paths := podSpecPathsFor(gvk, configured)
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("failed to Unmarshal %s: %s", gvk, err)
+	}
+
+	specs := []podSpec{}
+	for _, path := range paths {
+		v, found := lookupPointer(doc, path)
+		if !found {
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s at %s: %s", gvk, path, err)
+		}
+		s := podSpec{Path: path, Raw: v}
+		if err := json.Unmarshal(b, &s.Spec); err != nil {
+			return nil, fmt.Errorf("failed to Unmarshal PodSpec of %s at %s: %s", gvk, path, err)
+		}
+
+		metaPath := metadataPath(path)
+		meta, found := lookupPointer(doc, metaPath)
+		s.NoMeta = !found
+		if found {
+			b, err := json.Marshal(meta)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode %s at %s: %s", gvk, metaPath, err)
+			}
+			if err := json.Unmarshal(b, &s.Meta); err != nil {
+				return nil, fmt.Errorf("failed to Unmarshal metadata of %s at %s: %s", gvk, metaPath, err)
+			}
+		}
+
+		specs = append(specs, s)
+	}
+
+	return specs, nil
+}
+
+// metadataPath returns the JSON pointer of the metadata next to the PodSpec at specPath,
+// e.g. /spec/template/metadata for /spec/template/spec.
+func metadataPath(specPath string) string {
+	return path.Join(path.Dir(specPath), "metadata")
+}
+
+// lookupPointer returns the value the JSON pointer p points to within the decoded JSON document doc.
+// @see https://tools.ietf.org/html/rfc6901
+func lookupPointer(doc interface{}, p string) (interface{}, bool) {
+	if p == "" {
+		return doc, true
+	}
+	if !strings.HasPrefix(p, "/") {
+		return nil, false
+	}
+
+	v := doc
+	for _, token := range strings.Split(p[1:], "/") {
+		token = unescapePointerToken(token)
+		switch node := v.(type) {
+		case map[string]interface{}:
+			child, found := node[token]
+			if !found {
+				return nil, false
+			}
+			v = child
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return v, true
+}
+
+func unescapePointerToken(token string) string {
+	return strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+}
+
+func escapePointerToken(token string) string {
+	return strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+}
+
+// annotationPatches returns the patches adding annotations to the metadata next to the PodSpec s.
+// All annotations for s have to be added at once, since the patches may create the annotations.
+// Missing metadata is only created for pods and pod templates, other schemas (e.g. of custom resources)
+// may not have metadata next to their PodSpec, so they don't get annotated.
+func annotationPatches(s podSpec, annotations map[string]string) []Patch {
+	metaPath := metadataPath(s.Path)
+	if s.NoMeta {
+		if s.Path != "/spec" && !strings.HasSuffix(s.Path, "/template/spec") {
+			return nil
+		}
+		return []Patch{{Op: "add", Path: metaPath, Value: map[string]interface{}{"annotations": annotations}}}
+	}
+	if s.Meta.Annotations == nil {
+		return []Patch{{Op: "add", Path: metaPath + "/annotations", Value: annotations}}
+	}
+
+	keys := []string{}
+	for key := range annotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	patches := []Patch{}
+	for _, key := range keys {
+		patches = append(patches, Patch{Op: "add", Path: metaPath + "/annotations/" + escapePointerToken(key), Value: annotations[key]})
+	}
+
+	return patches
+}
@@ -0,0 +1,221 @@
+package webhook
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func Test_findPodSpecs(t *testing.T) {
+	tests := []struct {
+		name      string
+		gvk       schema.GroupVersionKind
+		config    []PodSpecPath
+		raw       string
+		wantPaths []string
+		wantNames []string
+	}{
+		{
+			name:      "Pod",
+			gvk:       schema.GroupVersionKind{Group: "", Version: "v1", Kind: "Pod"},
+			raw:       `{"spec":{"containers":[{"name":"nginx"}]}}`,
+			wantPaths: []string{"/spec"},
+			wantNames: []string{"nginx"},
+		},
+		{
+			name:      "Deployment",
+			gvk:       schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+			raw:       `{"spec":{"replicas":1,"template":{"metadata":{"labels":{"app":"nginx"}},"spec":{"containers":[{"name":"nginx"}]}}}}`,
+			wantPaths: []string{"/spec/template/spec"},
+			wantNames: []string{"nginx"},
+		},
+		{
+			name:      "CronJob",
+			gvk:       schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "CronJob"},
+			raw:       `{"spec":{"schedule":"* * * * *","jobTemplate":{"spec":{"template":{"spec":{"containers":[{"name":"backup"}]}}}}}}`,
+			wantPaths: []string{"/spec/jobTemplate/spec/template/spec"},
+			wantNames: []string{"backup"},
+		},
+		{
+			name: "configured Rollout",
+			gvk:  schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"},
+			config: []PodSpecPath{
+				{Group: "argoproj.io", Kind: "Rollout", Paths: []string{"/spec/template/spec"}},
+			},
+			raw:       `{"spec":{"template":{"spec":{"containers":[{"name":"app"}]}}}}`,
+			wantPaths: []string{"/spec/template/spec"},
+			wantNames: []string{"app"},
+		},
+		{
+			name: "configured version does not match",
+			gvk:  schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"},
+			config: []PodSpecPath{
+				{Group: "argoproj.io", Version: "v1", Kind: "Rollout", Paths: []string{"/spec/template/spec"}},
+			},
+			raw: `{"spec":{"template":{"spec":{"containers":[{"name":"app"}]}}}}`,
+		},
+		{
+			name: "configured multiple paths",
+			gvk:  schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Pipeline"},
+			config: []PodSpecPath{
+				{Group: "example.com", Kind: "Pipeline", Paths: []string{"/spec/build/spec", "/spec/deploy/spec"}},
+			},
+			raw:       `{"spec":{"build":{"spec":{"containers":[{"name":"build"}]}},"deploy":{"spec":{"containers":[{"name":"deploy"}]}}}}`,
+			wantPaths: []string{"/spec/build/spec", "/spec/deploy/spec"},
+			wantNames: []string{"build", "deploy"},
+		},
+		{
+			name: "unknown kind",
+			gvk:  schema.GroupVersionKind{Group: "", Version: "v1", Kind: "ConfigMap"},
+			raw:  `{"data":{"foo":"bar"}}`,
+		},
+		{
+			name: "missing PodSpec",
+			gvk:  schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+			raw:  `{"spec":{"replicas":1}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findPodSpecs(tt.gvk, []byte(tt.raw), tt.config)
+			if err != nil {
+				t.Fatalf("findPodSpecs() error = %v", err)
+			}
+			if len(got) != len(tt.wantPaths) {
+				t.Fatalf("findPodSpecs() = %v, want paths %v", got, tt.wantPaths)
+			}
+			for i, s := range got {
+				if s.Path != tt.wantPaths[i] {
+					t.Errorf("findPodSpecs() path = %s, want %s", s.Path, tt.wantPaths[i])
+				}
+				if s.Spec.Containers[0].Name != tt.wantNames[i] {
+					t.Errorf("findPodSpecs() container = %s, want %s", s.Spec.Containers[0].Name, tt.wantNames[i])
+				}
+			}
+		})
+	}
+}
+
+func Test_ownedByTemplateOwner(t *testing.T) {
+	replicaSet := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "ReplicaSet"}
+	job := schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}
+	tests := []struct {
+		name string
+		gvk  schema.GroupVersionKind
+		raw  string
+		want bool
+	}{
+		{
+			name: "ReplicaSet of a Deployment",
+			gvk:  replicaSet,
+			raw:  `{"metadata":{"ownerReferences":[{"apiVersion":"apps/v1","kind":"Deployment","name":"nginx","uid":"1"}]}}`,
+			want: true,
+		},
+		{
+			name: "Job of a CronJob",
+			gvk:  job,
+			raw:  `{"metadata":{"ownerReferences":[{"apiVersion":"batch/v1","kind":"CronJob","name":"backup","uid":"1"}]}}`,
+			want: true,
+		},
+		{
+			name: "standalone ReplicaSet",
+			gvk:  replicaSet,
+			raw:  `{"metadata":{"name":"nginx"}}`,
+		},
+		{
+			name: "Job owned by another controller",
+			gvk:  job,
+			raw:  `{"metadata":{"ownerReferences":[{"apiVersion":"example.com/v1","kind":"CronJob","name":"backup","uid":"1"}]}}`,
+		},
+		{
+			name: "Pod of a ReplicaSet",
+			gvk:  schema.GroupVersionKind{Group: "", Version: "v1", Kind: "Pod"},
+			raw:  `{"metadata":{"ownerReferences":[{"apiVersion":"apps/v1","kind":"ReplicaSet","name":"nginx-1","uid":"1"}]}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ownedByTemplateOwner(tt.gvk, []byte(tt.raw))
+			if err != nil {
+				t.Fatalf("ownedByTemplateOwner() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ownedByTemplateOwner() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_lookupPointer(t *testing.T) {
+	doc := map[string]interface{}{
+		"a/b": "slash",
+		"m~n": "tilde",
+		"list": []interface{}{
+			map[string]interface{}{"name": "first"},
+		},
+	}
+	tests := []struct {
+		pointer   string
+		want      interface{}
+		wantFound bool
+	}{
+		{pointer: "/a~1b", want: "slash", wantFound: true},
+		{pointer: "/m~0n", want: "tilde", wantFound: true},
+		{pointer: "/list/0/name", want: "first", wantFound: true},
+		{pointer: "/list/1/name"},
+		{pointer: "/list/x"},
+		{pointer: "/missing"},
+		{pointer: "missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pointer, func(t *testing.T) {
+			got, found := lookupPointer(doc, tt.pointer)
+			if found != tt.wantFound || (found && got != tt.want) {
+				t.Errorf("lookupPointer() = %v %v, want %v %v", got, found, tt.want, tt.wantFound)
+			}
+		})
+	}
+}
+
+func Test_annotationPatches(t *testing.T) {
+	annotations := map[string]string{"default-resources.io/fixed": "{}"}
+	tests := []struct {
+		name string
+		s    podSpec
+		want []Patch
+	}{
+		{
+			name: "existing annotations",
+			s:    podSpec{Path: "/spec", Meta: metav1.ObjectMeta{Annotations: map[string]string{"a": "b"}}},
+			want: []Patch{{Op: "add", Path: "/metadata/annotations/default-resources.io~1fixed", Value: "{}"}},
+		},
+		{
+			name: "no annotations",
+			s:    podSpec{Path: "/spec/template/spec"},
+			want: []Patch{{Op: "add", Path: "/spec/template/metadata/annotations", Value: annotations}},
+		},
+		{
+			name: "no metadata",
+			s:    podSpec{Path: "/spec/template/spec", NoMeta: true},
+			want: []Patch{{Op: "add", Path: "/spec/template/metadata", Value: map[string]interface{}{"annotations": annotations}}},
+		},
+		{
+			name: "no metadata of a pod",
+			s:    podSpec{Path: "/spec", NoMeta: true},
+			want: []Patch{{Op: "add", Path: "/metadata", Value: map[string]interface{}{"annotations": annotations}}},
+		},
+		{
+			name: "no metadata next to the PodSpec of a custom resource",
+			s:    podSpec{Path: "/spec/podSpec", NoMeta: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := annotationPatches(tt.s, annotations); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("annotationPatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
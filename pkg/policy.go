@@ -0,0 +1,205 @@
+package webhook
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	k8s_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// defaultRuleName is reported for containers no rule of the policy matches.
+const defaultRuleName = "default"
+
+// Policy selects the defaults of each container.
+type Policy struct {
+	// Rules are evaluated in order, the first matching rule wins.
+	Rules []Rule
+	// Default is used for containers no rule matches.
+	Default Defaults
+	// DefaultRule is reported as rule for containers no rule matches, empty reports "default".
+	DefaultRule string
+	// Profiles are named defaults pods select per container by annotation.
+	Profiles map[string]Defaults
+	// AllowPodAnnotations lets pods skip containers and select profiles by annotation.
+	AllowPodAnnotations bool
+}
+
+// Rule applies its Defaults to the containers selected by Match.
+type Rule struct {
+	Name     string   `json:"name"`
+	Match    Match    `json:"match,omitempty"`
+	Defaults Defaults `json:"defaults"`
+}
+
+// Match selects containers. Every given criterion has to match,
+// within a criterion any of the listed patterns is enough.
+// Patterns are globs, `*` matches any sequence of characters and `?` a single one.
+type Match struct {
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Labels have to be present on the pod (or pod template) with the given values.
+	Labels         map[string]string `json:"labels,omitempty"`
+	ContainerNames []string          `json:"containerNames,omitempty"`
+	Images         []string          `json:"images,omitempty"`
+	// ImageRegexps are regular expressions matching the image, they aren't anchored.
+	ImageRegexps []string `json:"imageRegexps,omitempty"`
+	// Registries and Repositories match the parts of the normalized image,
+	// e.g. docker.io and library/nginx for nginx:1.25.
+	Registries   []string `json:"registries,omitempty"`
+	Repositories []string `json:"repositories,omitempty"`
+	// Pinned matches how the image version is pinned: digest, tag (any but latest) or none.
+	Pinned []string `json:"pinned,omitempty"`
+
+	// compiled are the compiled patterns, set when the rule is validated or loaded.
+	compiled *matchPatterns
+}
+
+// matchPatterns are the compiled patterns of a Match.
+type matchPatterns struct {
+	namespaces     []*regexp.Regexp
+	containerNames []*regexp.Regexp
+	images         []*regexp.Regexp
+	imageRegexps   []*regexp.Regexp
+	registries     []*regexp.Regexp
+	repositories   []*regexp.Regexp
+}
+
+// defaultsFor returns the defaults and the name of the rule providing them
+// for container c of a pod with podLabels in namespace.
+func (p Policy) defaultsFor(namespace string, podLabels map[string]string, c k8s_v1.Container) (string, Defaults) {
+	for _, r := range p.Rules {
+		if r.Match.matches(namespace, podLabels, c) {
+			return r.Name, r.Defaults
+		}
+	}
+
+	if p.DefaultRule != "" {
+		return p.DefaultRule, p.Default
+	}
+	return defaultRuleName, p.Default
+}
+
+func (m Match) matches(namespace string, podLabels map[string]string, c k8s_v1.Container) bool {
+	if m.compiled == nil {
+		m.compiled, _ = m.compile()
+	}
+	if len(m.Namespaces) > 0 && !matchesAny(m.compiled.namespaces, namespace) {
+		return false
+	}
+	if len(m.Labels) > 0 && !labels.SelectorFromSet(m.Labels).Matches(labels.Set(podLabels)) {
+		return false
+	}
+	if len(m.ContainerNames) > 0 && !matchesAny(m.compiled.containerNames, c.Name) {
+		return false
+	}
+
+	return m.matchesImage(c.Image)
+}
+
+func matchesAny(patterns []*regexp.Regexp, s string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compile compiles the patterns of m. Invalid regular expressions are left out and the first of their errors returned.
+func (m Match) compile() (*matchPatterns, error) {
+	var err error
+	p := &matchPatterns{
+		namespaces:     globRegexps(m.Namespaces),
+		containerNames: globRegexps(m.ContainerNames),
+		images:         globRegexps(m.Images),
+		registries:     globRegexps(m.Registries),
+		repositories:   globRegexps(m.Repositories),
+	}
+	for _, pattern := range m.ImageRegexps {
+		r, compileErr := regexp.Compile(pattern)
+		if compileErr != nil {
+			if err == nil {
+				err = fmt.Errorf("imageRegexps: %s", compileErr)
+			}
+			continue
+		}
+		p.imageRegexps = append(p.imageRegexps, r)
+	}
+
+	return p, err
+}
+
+func globRegexps(patterns []string) []*regexp.Regexp {
+	regexps := []*regexp.Regexp{}
+	for _, pattern := range patterns {
+		regexps = append(regexps, globRegexp(pattern))
+	}
+
+	return regexps
+}
+
+// globRegexp translates a glob pattern into an anchored regular expression.
+func globRegexp(pattern string) *regexp.Regexp {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.Replace(expr, `\*`, `.*`, -1)
+	expr = strings.Replace(expr, `\?`, `.`, -1)
+
+	return regexp.MustCompile("^" + expr + "$")
+}
+
+// validateRules checks that the rules are named uniquely and their defaults are consistent,
+// and compiles the patterns of their matches.
+func validateRules(rules []Rule) error {
+	names := map[string]bool{defaultRuleName: true}
+	for i, r := range rules {
+		if r.Name == "" {
+			return fmt.Errorf("rules[%d]: name is required", i)
+		}
+		if names[r.Name] || strings.HasPrefix(r.Name, sidecarRulePrefix) {
+			return fmt.Errorf("rules[%d]: name %q is not unique (or reserved)", i, r.Name)
+		}
+		names[r.Name] = true
+
+		if err := rules[i].Match.validate(); err != nil {
+			return fmt.Errorf("rules[%d] %s: %s", i, r.Name, err)
+		}
+		if err := r.Defaults.validate(); err != nil {
+			return fmt.Errorf("rules[%d] %s: %s", i, r.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (d Defaults) validate() error {
+	if err := checkRequestsWithinLimits(d.Containers); err != nil {
+		return fmt.Errorf("containers: %s", err)
+	}
+	if d.InitContainers != nil {
+		if err := checkRequestsWithinLimits(*d.InitContainers); err != nil {
+			return fmt.Errorf("initContainers: %s", err)
+		}
+	}
+	if err := d.validateBounds(); err != nil {
+		return err
+	}
+	if err := d.validateLimitRequestRatio(); err != nil {
+		return err
+	}
+	if err := d.validateRequestAboveLimit(); err != nil {
+		return err
+	}
+	if d.QoS != "" && d.QoS != qosGuaranteed {
+		return fmt.Errorf("qos %q is not %s", d.QoS, qosGuaranteed)
+	}
+	if err := d.validateCPULimit(); err != nil {
+		return err
+	}
+	if err := d.validateLimitFactors(); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"testing"
+
+	k8s_v1 "k8s.io/api/core/v1"
+)
+
+func TestPolicy_defaultsFor(t *testing.T) {
+	small := parseTestResourceRequirements("128M", "0.1", "64M", "0.05")
+	large := parseTestResourceRequirements("4G", "2", "2G", "1")
+	policy := Policy{
+		Rules: []Rule{
+			{Name: "system", Match: Match{Namespaces: []string{"kube-*", "monitoring"}}, Defaults: Defaults{Containers: small}},
+			{Name: "batch", Match: Match{Labels: map[string]string{"tier": "batch"}}, Defaults: Defaults{Containers: large}},
+			{Name: "proxy", Match: Match{ContainerNames: []string{"istio-proxy"}, Images: []string{"docker.io/istio/*"}}, Defaults: Defaults{Containers: small}},
+			{Name: "java", Match: Match{Images: []string{"*/openjdk:1?"}}, Defaults: Defaults{Containers: large}},
+		},
+		Default: Defaults{Containers: defaults},
+	}
+
+	tests := []struct {
+		name      string
+		namespace string
+		labels    map[string]string
+		container k8s_v1.Container
+		wantRule  string
+	}{
+		{
+			name:      "namespace glob",
+			namespace: "kube-system",
+			container: k8s_v1.Container{Name: "coredns"},
+			wantRule:  "system",
+		},
+		{
+			name:      "first matching rule wins",
+			namespace: "monitoring",
+			labels:    map[string]string{"tier": "batch"},
+			container: k8s_v1.Container{Name: "job"},
+			wantRule:  "system",
+		},
+		{
+			name:      "labels",
+			namespace: "team-a",
+			labels:    map[string]string{"tier": "batch", "app": "report"},
+			container: k8s_v1.Container{Name: "job"},
+			wantRule:  "batch",
+		},
+		{
+			name:      "labels with other value",
+			namespace: "team-a",
+			labels:    map[string]string{"tier": "web"},
+			container: k8s_v1.Container{Name: "web"},
+			wantRule:  defaultRuleName,
+		},
+		{
+			name:      "container name and image",
+			namespace: "team-a",
+			container: k8s_v1.Container{Name: "istio-proxy", Image: "docker.io/istio/proxyv2:1.20.0"},
+			wantRule:  "proxy",
+		},
+		{
+			name:      "container name without image",
+			namespace: "team-a",
+			container: k8s_v1.Container{Name: "istio-proxy", Image: "example.com/proxy:1.0"},
+			wantRule:  defaultRuleName,
+		},
+		{
+			name:      "image glob with single character",
+			namespace: "team-a",
+			container: k8s_v1.Container{Name: "app", Image: "library/openjdk:17"},
+			wantRule:  "java",
+		},
+		{
+			name:      "image glob is anchored",
+			namespace: "team-a",
+			container: k8s_v1.Container{Name: "app", Image: "library/openjdk:17-slim"},
+			wantRule:  defaultRuleName,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, d := policy.defaultsFor(tt.namespace, tt.labels, tt.container)
+			if rule != tt.wantRule {
+				t.Errorf("defaultsFor() rule = %q, want %q", rule, tt.wantRule)
+			}
+			if rule == defaultRuleName && !equalResources(d.Containers, defaults) {
+				t.Errorf("defaultsFor() = %v, want the policy default %v", d.Containers, defaults)
+			}
+		})
+	}
+}
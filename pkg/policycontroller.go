@@ -0,0 +1,250 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pandorasnox/kubernetes-default-container-resources/pkg/apis/v1alpha1"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Prefixes of the rule names of the policy custom resources,
+// e.g. ClusterResourceDefaultPolicy/system or ResourceDefaultPolicy/team-a/small.
+const (
+	clusterPolicyRulePrefix = "ClusterResourceDefaultPolicy/"
+	policyRulePrefix        = "ResourceDefaultPolicy/"
+)
+
+// PolicyController provides the ClusterResourceDefaultPolicy and ResourceDefaultPolicy custom resources as rules
+// and reports the objects they defaulted and their validation errors in their status.
+type PolicyController struct {
+	client     dynamic.Interface
+	cluster    cache.SharedIndexInformer
+	namespaced cache.SharedIndexInformer
+
+	mu sync.Mutex
+	// defaulted counts the defaulted objects per rule name, which aren't reported in the status yet.
+	defaulted map[string]int64
+}
+
+// NewPolicyController starts informers caching the policies until stop is closed
+// and returns a controller backed by them, once the caches are synced.
+func NewPolicyController(client dynamic.Interface, resync time.Duration, stop <-chan struct{}) (*PolicyController, error) {
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(client, resync)
+	c := &PolicyController{
+		client:     client,
+		cluster:    factory.ForResource(v1alpha1.ClusterResourceDefaultPolicies).Informer(),
+		namespaced: factory.ForResource(v1alpha1.ResourceDefaultPolicies).Informer(),
+		defaulted:  map[string]int64{},
+	}
+	factory.Start(stop)
+
+	if !cache.WaitForCacheSync(stop, c.cluster.HasSynced, c.namespaced.HasSynced) {
+		return nil, fmt.Errorf("failed to sync policy cache")
+	}
+
+	return c, nil
+}
+
+// Rules returns the valid policies for pods in namespace as rules,
+// the ResourceDefaultPolicies of the namespace before the ClusterResourceDefaultPolicies.
+func (c *PolicyController) Rules(namespace string) []Rule {
+	rules := []Rule{}
+
+	namespaced, err := c.namespaced.GetIndexer().ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"namespace": namespace,
+		}).Warnf("failed to list ResourceDefaultPolicies: %s", err)
+	}
+	for _, p := range sortedPolicies(namespaced) {
+		if len(p.errs) == 0 {
+			// the namespace of the policy selects the pods
+			p.rule.Match.Namespaces = nil
+			rules = append(rules, p.rule)
+		}
+	}
+	for _, p := range sortedPolicies(c.cluster.GetStore().List()) {
+		if len(p.errs) == 0 {
+			rules = append(rules, p.rule)
+		}
+	}
+
+	return rules
+}
+
+// Defaulted counts an object defaulted by the rules, rules not provided by a policy are ignored.
+func (c *PolicyController) Defaulted(rules []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, r := range rules {
+		if strings.HasPrefix(r, clusterPolicyRulePrefix) || strings.HasPrefix(r, policyRulePrefix) {
+			c.defaulted[r]++
+		}
+	}
+}
+
+// Run updates the status of the policies every interval until stop is closed.
+func (c *PolicyController) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			c.updateStatus(context.Background())
+		}
+	}
+}
+
+// updateStatus reports the defaulted objects and validation errors of all policies, which changed since the last update.
+// Counts failing to be reported are kept for the next update.
+func (c *PolicyController) updateStatus(ctx context.Context) {
+	live := map[string]bool{}
+	for _, i := range []struct {
+		informer cache.SharedIndexInformer
+		resource schema.GroupVersionResource
+	}{
+		{informer: c.cluster, resource: v1alpha1.ClusterResourceDefaultPolicies},
+		{informer: c.namespaced, resource: v1alpha1.ResourceDefaultPolicies},
+	} {
+		for _, p := range decodePolicies(i.informer.GetStore().List()) {
+			live[p.rule.Name] = true
+
+			c.mu.Lock()
+			n := c.defaulted[p.rule.Name]
+			c.mu.Unlock()
+
+			current, _, _ := unstructured.NestedInt64(p.obj.Object, "status", "defaultedObjects")
+			observed, _, _ := unstructured.NestedInt64(p.obj.Object, "status", "observedGeneration")
+			errs, _, _ := unstructured.NestedStringSlice(p.obj.Object, "status", "validationErrors")
+			if n == 0 && observed == p.obj.GetGeneration() && reflect.DeepEqual(errs, p.errs) {
+				continue
+			}
+
+			status, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&v1alpha1.ResourceDefaultPolicyStatus{
+				ObservedGeneration: p.obj.GetGeneration(),
+				DefaultedObjects:   current + n,
+				ValidationErrors:   p.errs,
+			})
+			if err != nil {
+				logrus.Errorf("failed to encode status of %s: %s", p.rule.Name, err)
+				continue
+			}
+			obj := p.obj.DeepCopy()
+			if err := unstructured.SetNestedField(obj.Object, status, "status"); err != nil {
+				logrus.Errorf("failed to set status of %s: %s", p.rule.Name, err)
+				continue
+			}
+			if _, err := c.client.Resource(i.resource).Namespace(obj.GetNamespace()).UpdateStatus(ctx, obj, metav1.UpdateOptions{}); err != nil {
+				logrus.Warnf("failed to update status of %s, retrying with the next update: %s", p.rule.Name, err)
+				continue
+			}
+
+			c.mu.Lock()
+			c.defaulted[p.rule.Name] -= n
+			c.mu.Unlock()
+		}
+	}
+
+	// forget the counts of deleted policies
+	c.mu.Lock()
+	for name := range c.defaulted {
+		if !live[name] {
+			delete(c.defaulted, name)
+		}
+	}
+	c.mu.Unlock()
+}
+
+// policy is a decoded policy custom resource.
+type policy struct {
+	obj      *unstructured.Unstructured
+	priority int32
+	rule     Rule
+	// errs are the validation errors of the policy, an invalid policy provides no rule.
+	errs []string
+}
+
+// sortedPolicies decodes the cached policies objs, ordered by priority and name.
+func sortedPolicies(objs []interface{}) []policy {
+	policies := decodePolicies(objs)
+	sort.SliceStable(policies, func(i, j int) bool {
+		if policies[i].priority != policies[j].priority {
+			return policies[i].priority > policies[j].priority
+		}
+		return policies[i].rule.Name < policies[j].rule.Name
+	})
+
+	return policies
+}
+
+func decodePolicies(objs []interface{}) []policy {
+	policies := []policy{}
+	for _, obj := range objs {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		policies = append(policies, decodePolicy(u))
+	}
+
+	return policies
+}
+
+// decodePolicy decodes and validates u, a ClusterResourceDefaultPolicy or ResourceDefaultPolicy.
+// Both kinds share the same spec.
+func decodePolicy(u *unstructured.Unstructured) policy {
+	name := clusterPolicyRulePrefix + u.GetName()
+	if u.GetNamespace() != "" {
+		name = policyRulePrefix + u.GetNamespace() + "/" + u.GetName()
+	}
+	p := policy{obj: u, rule: Rule{Name: name}}
+
+	decoded := v1alpha1.ResourceDefaultPolicy{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), &decoded); err != nil {
+		p.errs = append(p.errs, fmt.Sprintf("failed to decode spec: %s", err))
+		return p
+	}
+
+	p.priority = decoded.Spec.Priority
+	p.rule.Match = matchFromPolicy(decoded.Spec.Match)
+	p.rule.Defaults = Defaults(decoded.Spec.Defaults)
+	if err := p.rule.Match.validate(); err != nil {
+		p.errs = append(p.errs, err.Error())
+	}
+	if err := p.rule.Defaults.validate(); err != nil {
+		p.errs = append(p.errs, err.Error())
+	}
+
+	return p
+}
+
+// matchFromPolicy converts the match of a policy, its patterns get compiled by validate.
+func matchFromPolicy(m v1alpha1.PolicyMatch) Match {
+	return Match{
+		Namespaces:     m.Namespaces,
+		Labels:         m.Labels,
+		ContainerNames: m.ContainerNames,
+		Images:         m.Images,
+		ImageRegexps:   m.ImageRegexps,
+		Registries:     m.Registries,
+		Repositories:   m.Repositories,
+		Pinned:         m.Pinned,
+	}
+}
@@ -0,0 +1,162 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pandorasnox/kubernetes-default-container-resources/pkg/apis/v1alpha1"
+	k8s_v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func testPolicy(kind, namespace, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	u.SetAPIVersion(v1alpha1.SchemeGroupVersion.String())
+	u.SetKind(kind)
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	u.SetGeneration(1)
+	return u
+}
+
+func testPolicyController(t *testing.T, objs ...runtime.Object) (*PolicyController, *fake.FakeDynamicClient) {
+	// the scheme stays empty, the fake keeps the objects unstructured like the api server does for custom resources
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		v1alpha1.ClusterResourceDefaultPolicies: "ClusterResourceDefaultPolicyList",
+		v1alpha1.ResourceDefaultPolicies:        "ResourceDefaultPolicyList",
+	}, objs...)
+
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	c, err := NewPolicyController(client, 0, stop)
+	if err != nil {
+		t.Fatalf("NewPolicyController() error = %v", err)
+	}
+	return c, client
+}
+
+func testPolicySpec(priority int64, limitMemory string) map[string]interface{} {
+	return map[string]interface{}{
+		"priority": priority,
+		"defaults": map[string]interface{}{
+			"containers": map[string]interface{}{
+				"limits": map[string]interface{}{"memory": limitMemory},
+			},
+		},
+	}
+}
+
+func TestPolicyController_Rules(t *testing.T) {
+	c, _ := testPolicyController(t,
+		testPolicy("ClusterResourceDefaultPolicy", "", "system", testPolicySpec(0, "256Mi")),
+		testPolicy("ClusterResourceDefaultPolicy", "", "important", testPolicySpec(10, "2Gi")),
+		testPolicy("ResourceDefaultPolicy", "team-a", "small", testPolicySpec(0, "128Mi")),
+		testPolicy("ResourceDefaultPolicy", "team-b", "large", testPolicySpec(0, "4Gi")),
+		testPolicy("ResourceDefaultPolicy", "team-a", "invalid", map[string]interface{}{
+			"defaults": map[string]interface{}{
+				"containers": map[string]interface{}{
+					"limits":   map[string]interface{}{"cpu": "100m"},
+					"requests": map[string]interface{}{"cpu": "1"},
+				},
+			},
+		}),
+	)
+
+	names := []string{}
+	for _, r := range c.Rules("team-a") {
+		names = append(names, r.Name)
+	}
+	want := "ResourceDefaultPolicy/team-a/small,ClusterResourceDefaultPolicy/important,ClusterResourceDefaultPolicy/system"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("Rules() = %s, want %s", got, want)
+	}
+}
+
+func TestPolicyController_updateStatus(t *testing.T) {
+	c, client := testPolicyController(t,
+		testPolicy("ClusterResourceDefaultPolicy", "", "system", testPolicySpec(0, "256Mi")),
+		testPolicy("ResourceDefaultPolicy", "team-a", "invalid", map[string]interface{}{
+			"defaults": map[string]interface{}{
+				"containers": map[string]interface{}{
+					"limits": map[string]interface{}{"memory": "no quantity"},
+				},
+			},
+		}),
+	)
+
+	c.Defaulted([]string{"ClusterResourceDefaultPolicy/system", "default"})
+	c.Defaulted([]string{"ClusterResourceDefaultPolicy/system"})
+	c.updateStatus(context.Background())
+
+	system, err := client.Resource(v1alpha1.ClusterResourceDefaultPolicies).Get(context.Background(), "system", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _, _ := unstructured.NestedInt64(system.Object, "status", "defaultedObjects"); n != 2 {
+		t.Errorf("status.defaultedObjects = %d, want 2", n)
+	}
+	if c.defaulted["ClusterResourceDefaultPolicy/system"] != 0 {
+		t.Errorf("reported count %d not reset", c.defaulted["ClusterResourceDefaultPolicy/system"])
+	}
+	if _, found := c.defaulted["default"]; found {
+		t.Error("counted rule default, which is no policy")
+	}
+
+	invalid, err := client.Resource(v1alpha1.ResourceDefaultPolicies).Namespace("team-a").Get(context.Background(), "invalid", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs, _, _ := unstructured.NestedStringSlice(invalid.Object, "status", "validationErrors"); len(errs) != 1 {
+		t.Errorf("status.validationErrors = %v, want one error", errs)
+	}
+}
+
+func TestMutator_Mutate_policies(t *testing.T) {
+	c, _ := testPolicyController(t,
+		testPolicy("ResourceDefaultPolicy", "team-a", "small", testPolicySpec(0, "128Mi")),
+	)
+	m := &Mutator{
+		Policy:   Policy{Default: Defaults{Containers: defaults}},
+		Strategy: ComplementToDefault{},
+		Policies: c,
+	}
+
+	tests := []struct {
+		name      string
+		namespace string
+		dryRun    bool
+		want      string
+	}{
+		{name: "policy", namespace: "team-a", want: "128Mi"},
+		{name: "server-side dry-run", namespace: "team-a", dryRun: true, want: "128Mi"},
+		{name: "other namespace", namespace: "team-b", want: limitMemory},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := `{"spec":{"containers":[{"name":"nginx"}]}}`
+			body := `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","request":{"uid":"1","kind":{"group":"","version":"v1","kind":"Pod"},"namespace":"` + tt.namespace + `","operation":"CREATE","dryRun":` + strconv.FormatBool(tt.dryRun) + `,"object":` + pod + `}}`
+			w := httptest.NewRecorder()
+			if err := m.Mutate(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))); err != nil {
+				t.Fatalf("Mutate() error = %v", err)
+			}
+
+			resources := decodeTestResponsePatch(t, pod, w.Body.Bytes())["/spec/containers/0/resources"]
+			got := resources.Limits[k8s_v1.ResourceMemory]
+			if got.Cmp(getResourceQuantity(tt.want)) != 0 {
+				t.Errorf("Mutate() limits.memory = %s, want %s", got.String(), tt.want)
+			}
+		})
+	}
+
+	if n := c.defaulted["ResourceDefaultPolicy/team-a/small"]; n != 1 {
+		t.Errorf("counted %d defaulted pods, want 1", n)
+	}
+}
@@ -0,0 +1,79 @@
+package webhook
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/inf.v0"
+	k8s_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// enforceLimitRequestRatio checks the limits of r against the MaxLimitRequestRatio of d, like a LimitRange does.
+// With d.RaiseRequests a request below limit / ratio is raised to it, otherwise all violations are returned as one error.
+// Resources missing a request or limit aren't checked.
+func (d Defaults) enforceLimitRequestRatio(r k8s_v1.ResourceRequirements) (k8s_v1.ResourceRequirements, error) {
+	r = *r.DeepCopy()
+	violations := []string{}
+	for _, name := range sortedResourceNames(d.MaxLimitRequestRatio) {
+		ratio := d.MaxLimitRequestRatio[name]
+		limit, limitFound := r.Limits[name]
+		request, requestFound := r.Requests[name]
+		if !limitFound || !requestFound {
+			continue
+		}
+
+		// limit > request * ratio, compared exactly without dividing
+		max := new(inf.Dec).Mul(decimal(request), decimal(ratio))
+		if decimal(limit).Cmp(max) <= 0 {
+			continue
+		}
+
+		if d.RaiseRequests {
+			r.Requests[name] = minRequest(name, limit, ratio)
+			continue
+		}
+		violations = append(violations, fmt.Sprintf("limits.%s %s is more than %s times requests.%s %s", name, limit.String(), decimal(ratio).String(), name, request.String()))
+	}
+
+	if len(violations) > 0 {
+		return r, fmt.Errorf("%s", strings.Join(violations, ", "))
+	}
+
+	return r, nil
+}
+
+// minRequest returns limit / ratio, rounded up to millicores for cpu and to whole units otherwise.
+func minRequest(name k8s_v1.ResourceName, limit resource.Quantity, ratio resource.Quantity) resource.Quantity {
+	q := new(inf.Dec).QuoRound(decimal(limit), decimal(ratio), resourceScale(name), inf.RoundUp)
+
+	return *resource.NewDecimalQuantity(*q, limit.Format)
+}
+
+// resourceScale is the number of decimal places computed values of the resource name are rounded to:
+// millicores for cpu and whole units (e.g. bytes) otherwise.
+func resourceScale(name k8s_v1.ResourceName) inf.Scale {
+	if name == k8s_v1.ResourceCPU {
+		return 3
+	}
+	return 0
+}
+
+// decimal returns q as exact decimal, leaving q untouched.
+func decimal(q resource.Quantity) *inf.Dec {
+	q = q.DeepCopy()
+	return q.AsDec()
+}
+
+// validateLimitRequestRatio checks that no ratio is less than 1, which would require requests greater than their limits.
+func (d Defaults) validateLimitRequestRatio() error {
+	one := resource.MustParse("1")
+	for _, name := range sortedResourceNames(d.MaxLimitRequestRatio) {
+		ratio := d.MaxLimitRequestRatio[name]
+		if ratio.Cmp(one) < 0 {
+			return fmt.Errorf("maxLimitRequestRatio of %s is less than 1", name)
+		}
+	}
+
+	return nil
+}
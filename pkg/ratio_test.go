@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"strings"
+	"testing"
+
+	k8s_v1 "k8s.io/api/core/v1"
+)
+
+func TestDefaults_enforceLimitRequestRatio(t *testing.T) {
+	ratio := Defaults{
+		MaxLimitRequestRatio: k8s_v1.ResourceList{k8s_v1.ResourceCPU: getResourceQuantity("4"), k8s_v1.ResourceMemory: getResourceQuantity("1.5")},
+	}
+	raise := ratio
+	raise.RaiseRequests = true
+
+	tests := []struct {
+		name       string
+		d          Defaults
+		r          k8s_v1.ResourceRequirements
+		want       k8s_v1.ResourceRequirements
+		wantErrors []string
+	}{
+		{
+			name: "within ratio",
+			d:    ratio,
+			r:    parseTestResourceRequirements("1536Mi", "2", "1Gi", "500m"),
+			want: parseTestResourceRequirements("1536Mi", "2", "1Gi", "500m"),
+		},
+		{
+			name: "missing request isn't checked",
+			d:    ratio,
+			r:    parseTestResourceRequirements("8Gi", "2", "", ""),
+			want: parseTestResourceRequirements("8Gi", "2", "", ""),
+		},
+		{
+			name:       "deny lists every exceeded ratio",
+			d:          ratio,
+			r:          parseTestResourceRequirements("2Gi", "2", "1Gi", "100m"),
+			wantErrors: []string{"limits.cpu 2 is more than 4 times requests.cpu 100m", "limits.memory 2Gi is more than 1.5 times requests.memory 1Gi"},
+		},
+		{
+			name: "raise requests",
+			d:    raise,
+			r:    parseTestResourceRequirements("3Gi", "1", "1Gi", "100m"),
+			want: parseTestResourceRequirements("2Gi", "1", "2Gi", "250m"),
+		},
+		{
+			name: "raised requests round up",
+			d:    raise,
+			r:    parseTestResourceRequirements("1G", "1", "1", "1m"),
+			want: parseTestResourceRequirements("1G", "1", "666666667", "250m"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.d.enforceLimitRequestRatio(tt.r)
+			if len(tt.wantErrors) > 0 {
+				if err == nil {
+					t.Fatalf("enforceLimitRequestRatio() error = nil, want %v", tt.wantErrors)
+				}
+				for _, want := range tt.wantErrors {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("enforceLimitRequestRatio() error = %q, want it to contain %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("enforceLimitRequestRatio() error = %v", err)
+			}
+			if !equalResourceList(got.Requests, tt.want.Requests) {
+				t.Errorf("enforceLimitRequestRatio() requests = %v, want %v", got.Requests, tt.want.Requests)
+			}
+		})
+	}
+}
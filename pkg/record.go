@@ -0,0 +1,143 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+
+	k8s_v1 "k8s.io/api/core/v1"
+)
+
+// Annotations recording on the pod (template) the resource values set by the webhook per container name,
+// e.g. {"nginx":["limits.cpu","requests.memory"]}, and the rule (or namespace) supplying each of them,
+// e.g. {"nginx":{"limits.cpu":"team-a","requests.memory":"Namespace/foo"}}.
+const (
+	defaultedAnnotation   = annotationPrefix + "defaulted"
+	defaultedByAnnotation = annotationPrefix + "defaulted-by"
+)
+
+// defaultsSource names where the defaults of a container come from.
+type defaultsSource struct {
+	// Rule is the name of the rule providing the defaults.
+	Rule string
+	// Overrides names the sources of values replacing the ones of the rule by field, e.g. limits.memory: Namespace/foo.
+	Overrides map[string]string
+	// Skipped containers opted out of defaulting by annotation, they are only checked against the constraints.
+	Skipped bool
+}
+
+// of returns the source of the value of field.
+func (s defaultsSource) of(field string) string {
+	if source, found := s.Overrides[field]; found {
+		return source
+	}
+	return s.Rule
+}
+
+// podRecord collects the changes to the containers of a PodSpec by container name, recorded in its annotations.
+type podRecord struct {
+	Fixes       map[string][]string
+	Defaulted   map[string][]string
+	DefaultedBy map[string]map[string]string
+}
+
+func newPodRecord() *podRecord {
+	return &podRecord{
+		Fixes:       map[string][]string{},
+		Defaulted:   map[string][]string{},
+		DefaultedBy: map[string]map[string]string{},
+	}
+}
+
+// loadPodRecord returns the record of an earlier pass from the annotations of s, invalid annotations are dropped.
+func loadPodRecord(s podSpec) *podRecord {
+	r := &podRecord{}
+	for annotation, v := range map[string]interface{}{
+		fixesAnnotation:       &r.Fixes,
+		defaultedAnnotation:   &r.Defaulted,
+		defaultedByAnnotation: &r.DefaultedBy,
+	} {
+		if value := s.Meta.Annotations[annotation]; value != "" {
+			_ = json.Unmarshal([]byte(value), v)
+		}
+	}
+
+	fresh := newPodRecord()
+	if r.Fixes == nil {
+		r.Fixes = fresh.Fixes
+	}
+	if r.Defaulted == nil {
+		r.Defaulted = fresh.Defaulted
+	}
+	if r.DefaultedBy == nil {
+		r.DefaultedBy = fresh.DefaultedBy
+	}
+
+	return r
+}
+
+// add records the fields of container c, which got the resources r from source, and the fixes made.
+func (rec *podRecord) add(c k8s_v1.Container, r k8s_v1.ResourceRequirements, source defaultsSource, fixes []string) {
+	if len(fixes) > 0 {
+		rec.Fixes[c.Name] = fixes
+	}
+	fields := defaultedFields(c.Resources, r)
+	if len(fields) == 0 {
+		return
+	}
+	rec.Defaulted[c.Name] = fields
+	rec.DefaultedBy[c.Name] = map[string]string{}
+	for _, field := range fields {
+		rec.DefaultedBy[c.Name][field] = source.of(field)
+	}
+}
+
+// annotations returns the annotations of the record, records without entries are left out.
+func (rec *podRecord) annotations() (map[string]string, error) {
+	annotations := map[string]string{}
+	for _, a := range []struct {
+		annotation string
+		entries    int
+		value      interface{}
+	}{
+		{annotation: fixesAnnotation, entries: len(rec.Fixes), value: rec.Fixes},
+		{annotation: defaultedAnnotation, entries: len(rec.Defaulted), value: rec.Defaulted},
+		{annotation: defaultedByAnnotation, entries: len(rec.DefaultedBy), value: rec.DefaultedBy},
+	} {
+		if a.entries == 0 {
+			continue
+		}
+		value, err := json.Marshal(a.value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s annotation: %s", a.annotation, err)
+		}
+		annotations[a.annotation] = string(value)
+	}
+
+	return annotations, nil
+}
+
+// defaultedFields returns the fields set or changed from the container resources c to r, e.g. limits.cpu.
+func defaultedFields(c, r k8s_v1.ResourceRequirements) []string {
+	fields := []string{}
+	for _, l := range []struct {
+		field string
+		c, r  k8s_v1.ResourceList
+	}{
+		{field: "limits", c: c.Limits, r: r.Limits},
+		{field: "requests", c: c.Requests, r: r.Requests},
+	} {
+		for _, name := range sortedResourceNames(l.r) {
+			if q, found := l.c[name]; found && q.Cmp(l.r[name]) == 0 {
+				continue
+			}
+			fields = append(fields, l.field+"."+string(name))
+		}
+	}
+
+	return fields
+}
+
+// resourceFields returns the fields of the resources r, e.g. limits.cpu.
+func resourceFields(r k8s_v1.ResourceRequirements) []string {
+	return defaultedFields(k8s_v1.ResourceRequirements{}, r)
+}
@@ -0,0 +1,88 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	admission_v1 "k8s.io/api/admission/v1"
+	k8s_v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_defaultedFields(t *testing.T) {
+	tests := []struct {
+		name string
+		c    k8s_v1.ResourceRequirements
+		r    k8s_v1.ResourceRequirements
+		want []string
+	}{
+		{
+			name: "all fields defaulted",
+			c:    k8s_v1.ResourceRequirements{},
+			r:    defaults,
+			want: []string{"limits.cpu", "limits.memory", "requests.cpu", "requests.memory"},
+		},
+		{
+			name: "fields of the container are left out",
+			c:    parseTestResourceRequirements("", "500m", "", "100m"),
+			r:    defaults,
+			want: []string{"limits.memory", "requests.memory"},
+		},
+		{
+			name: "changed fields are included",
+			c:    parseTestResourceRequirements("", "", "2G", ""),
+			r:    parseTestResourceRequirements("2G", "", "2G", ""),
+			want: []string{"limits.memory"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := defaultedFields(tt.c, tt.r); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("defaultedFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMutate_defaultedAnnotations(t *testing.T) {
+	m := &Mutator{
+		Policy: Policy{
+			Rules:   []Rule{{Name: "proxies", Match: Match{ContainerNames: []string{"proxy"}}, Defaults: Defaults{Containers: defaults}}},
+			Default: Defaults{Containers: defaults},
+		},
+		Strategy: ComplementToDefault{},
+		Namespaces: testNamespaceLister(
+			testNamespace("foo", map[string]string{"default-resources.io/limit-memory": "4Gi"}),
+		),
+	}
+	pod := `{"metadata":{"name":"nginx"},"spec":{"containers":[{"name":"nginx","resources":{"limits":{"cpu":"500m"},"requests":{"cpu":"100m"}}},{"name":"proxy"}]}}`
+	body := `{"kind":"AdmissionReview","apiVersion":"admission.k8s.io/v1","request":{"uid":"1","kind":{"group":"","version":"v1","kind":"Pod"},"namespace":"foo","operation":"CREATE","object":` + pod + `}}`
+	w := httptest.NewRecorder()
+	if err := m.Mutate(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))); err != nil {
+		t.Fatalf("Mutate() error = %v", err)
+	}
+	out := admission_v1.AdmissionReview{}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	patched := struct {
+		Metadata metav1.ObjectMeta `json:"metadata"`
+	}{}
+	if err := json.Unmarshal(applyTestPatch(t, []byte(pod), out.Response.Patch), &patched); err != nil {
+		t.Fatalf("failed to decode patched pod: %v", err)
+	}
+	for annotation, want := range map[string]string{
+		defaultedAnnotation: `{"nginx":["limits.memory","requests.memory"],"proxy":["limits.cpu","limits.memory","requests.cpu","requests.memory"]}`,
+		defaultedByAnnotation: `{"nginx":{"limits.memory":"Namespace/foo","requests.memory":"default"},` +
+			`"proxy":{"limits.cpu":"proxies","limits.memory":"Namespace/foo","requests.cpu":"proxies","requests.memory":"proxies"}}`,
+	} {
+		if got := patched.Metadata.Annotations[annotation]; got != want {
+			t.Errorf("Mutate() annotation %s = %s, want %s", annotation, got, want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package webhook
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+// ConfigWatcher keeps the config of a file, e.g. of a mounted ConfigMap, up to date.
+// Invalid files are rejected and the last valid config is kept.
+type ConfigWatcher struct {
+	path string
+	// defaults are used for containers no rule matches, unless the config has its own default.
+	defaults Defaults
+	current  atomic.Pointer[loadedConfig]
+}
+
+// loadedConfig is a valid config together with the file content it was parsed from.
+type loadedConfig struct {
+	raw    []byte
+	config Config
+}
+
+// NewConfigWatcher loads the config file at path, which has to be valid.
+func NewConfigWatcher(path string, defaults Defaults) (*ConfigWatcher, error) {
+	w := &ConfigWatcher{path: path, defaults: defaults}
+	if _, err := w.Reload(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Load returns the active config and its policy, both from the same load,
+// so a reload in between doesn't mix two configs.
+func (w *ConfigWatcher) Load() (Config, Policy) {
+	c := w.current.Load().config
+	return c, c.Policy(w.defaults)
+}
+
+// Reload reads the config file and swaps the active config if the file changed.
+// It reports whether the config was swapped, an invalid file keeps the active config.
+func (w *ConfigWatcher) Reload() (bool, error) {
+	b, err := os.ReadFile(w.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read config file: %s", err)
+	}
+
+	old := w.current.Load()
+	if old != nil && bytes.Equal(old.raw, b) {
+		return false, nil
+	}
+
+	c, err := ParseConfig(b)
+	if err != nil {
+		return false, err
+	}
+	w.current.Store(&loadedConfig{raw: b, config: c})
+
+	if old != nil {
+		logrus.WithFields(logrus.Fields{
+			"path": w.path,
+			"diff": configDiff(old.config, c),
+		}).Info("reloaded config")
+	}
+
+	return true, nil
+}
+
+// Run reloads the config file every interval until stop is closed.
+func (w *ConfigWatcher) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if _, err := w.Reload(); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"path": w.path,
+				}).Errorf("rejected config, keeping the last valid one: %s", err)
+			}
+		}
+	}
+}
+
+// configDiff returns a line diff of the YAML representations of a and b,
+// so formatting and comments of the file don't show up.
+func configDiff(a, b Config) string {
+	ya, err := yaml.Marshal(a)
+	if err != nil {
+		return fmt.Sprintf("failed to encode old config: %s", err)
+	}
+	yb, err := yaml.Marshal(b)
+	if err != nil {
+		return fmt.Sprintf("failed to encode new config: %s", err)
+	}
+
+	return cmp.Diff(strings.Split(string(ya), "\n"), strings.Split(string(yb), "\n"))
+}
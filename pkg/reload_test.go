@@ -0,0 +1,110 @@
+package webhook
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigWatcher_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`rules: [{name: a, defaults: {containers: {}}}]`)
+	w, err := NewConfigWatcher(path, Defaults{Containers: defaults})
+	if err != nil {
+		t.Fatalf("NewConfigWatcher() error = %v", err)
+	}
+
+	ruleNames := func() string {
+		names := []string{}
+		_, policy := w.Load()
+		for _, r := range policy.Rules {
+			names = append(names, r.Name)
+		}
+		return strings.Join(names, ",")
+	}
+
+	steps := []struct {
+		name        string
+		content     string
+		wantSwapped bool
+		wantErr     bool
+		wantRules   string
+	}{
+		{
+			name:      "unchanged file",
+			content:   `rules: [{name: a, defaults: {containers: {}}}]`,
+			wantRules: "a",
+		},
+		{
+			name:        "valid change",
+			content:     `rules: [{name: a, defaults: {containers: {}}}, {name: b, defaults: {containers: {}}}]`,
+			wantSwapped: true,
+			wantRules:   "a,b",
+		},
+		{
+			name:      "invalid file keeps the last valid config",
+			content:   `rules: [{name: a}, {name: a}]`,
+			wantErr:   true,
+			wantRules: "a,b",
+		},
+		{
+			name:      "unparsable file keeps the last valid config",
+			content:   `rules: {`,
+			wantErr:   true,
+			wantRules: "a,b",
+		},
+		{
+			name:        "valid again",
+			content:     `rules: [{name: c, defaults: {containers: {}}}]`,
+			wantSwapped: true,
+			wantRules:   "c",
+		},
+	}
+	for _, tt := range steps {
+		write(tt.content)
+		swapped, err := w.Reload()
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: Reload() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if swapped != tt.wantSwapped {
+			t.Errorf("%s: Reload() = %v, want %v", tt.name, swapped, tt.wantSwapped)
+		}
+		if got := ruleNames(); got != tt.wantRules {
+			t.Errorf("%s: rules = %q, want %q", tt.name, got, tt.wantRules)
+		}
+	}
+}
+
+func TestNewConfigWatcher_invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(`unknown: true`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewConfigWatcher(path, Defaults{}); err == nil {
+		t.Error("NewConfigWatcher() error = nil, want an error for an invalid file")
+	}
+	if _, err := NewConfigWatcher(filepath.Join(t.TempDir(), "missing.yaml"), Defaults{}); err == nil {
+		t.Error("NewConfigWatcher() error = nil, want an error for a missing file")
+	}
+}
+
+func Test_configDiff(t *testing.T) {
+	a := Config{Rules: []Rule{{Name: "a"}}}
+	b := Config{Rules: []Rule{{Name: "b"}}}
+
+	if diff := configDiff(a, a); diff != "" {
+		t.Errorf("configDiff() of equal configs = %q, want none", diff)
+	}
+	diff := configDiff(a, b)
+	if !strings.Contains(diff, "name: a") || !strings.Contains(diff, "name: b") {
+		t.Errorf("configDiff() = %q, want the changed rule names", diff)
+	}
+}
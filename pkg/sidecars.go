@@ -0,0 +1,94 @@
+package webhook
+
+import (
+	"fmt"
+	"sort"
+
+	k8s_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// sidecarRulePrefix prefixes the names of the built-in sidecar rules, e.g. sidecar/istio-proxy.
+const sidecarRulePrefix = "sidecar/"
+
+// sidecarRules is the built-in library of rules for well-known sidecars, which need far less than the app they run next to.
+// They match by container name, where the name is ambiguous also by image.
+var sidecarRules = []Rule{
+	{
+		Name:     sidecarRulePrefix + "istio-proxy",
+		Match:    Match{ContainerNames: []string{"istio-proxy"}},
+		Defaults: sidecarDefaults("256Mi", "500m", "64Mi", "20m"),
+	},
+	{
+		Name:     sidecarRulePrefix + "linkerd-proxy",
+		Match:    Match{ContainerNames: []string{"linkerd-proxy"}},
+		Defaults: sidecarDefaults("128Mi", "250m", "32Mi", "10m"),
+	},
+	{
+		Name:     sidecarRulePrefix + "fluent-bit",
+		Match:    Match{ContainerNames: []string{"fluent-bit", "fluentbit"}, Repositories: []string{"*fluent-bit*"}},
+		Defaults: sidecarDefaults("128Mi", "200m", "32Mi", "10m"),
+	},
+	{
+		Name:     sidecarRulePrefix + "cloud-sql-proxy",
+		Match:    Match{ContainerNames: []string{"cloud-sql-proxy", "cloudsql-proxy"}, Repositories: []string{"*cloud-sql-proxy*", "*gce-proxy*"}},
+		Defaults: sidecarDefaults("128Mi", "250m", "32Mi", "10m"),
+	},
+}
+
+func sidecarDefaults(limitMemory, limitCPU, requestMemory, requestCPU string) Defaults {
+	return Defaults{Containers: k8s_v1.ResourceRequirements{
+		Limits: k8s_v1.ResourceList{
+			k8s_v1.ResourceMemory: resource.MustParse(limitMemory),
+			k8s_v1.ResourceCPU:    resource.MustParse(limitCPU),
+		},
+		Requests: k8s_v1.ResourceList{
+			k8s_v1.ResourceMemory: resource.MustParse(requestMemory),
+			k8s_v1.ResourceCPU:    resource.MustParse(requestCPU),
+		},
+	}}
+}
+
+// sidecarNames returns the names of the built-in sidecar rules, without prefix.
+func sidecarNames() []string {
+	names := []string{}
+	for _, r := range sidecarRules {
+		names = append(names, r.Name[len(sidecarRulePrefix):])
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// builtinSidecarRules returns the built-in sidecar rules with their defaults replaced by overrides, by sidecar name,
+// and their patterns compiled.
+func builtinSidecarRules(overrides map[string]Defaults) []Rule {
+	rules := []Rule{}
+	for _, r := range sidecarRules {
+		if d, found := overrides[r.Name[len(sidecarRulePrefix):]]; found {
+			r.Defaults = d
+		}
+		r.Match.compiled, _ = r.Match.compile()
+		rules = append(rules, r)
+	}
+
+	return rules
+}
+
+// validateSidecars checks that the overrides name built-in sidecars and their defaults are consistent.
+func validateSidecars(overrides map[string]Defaults) error {
+	known := map[string]bool{}
+	for _, name := range sidecarNames() {
+		known[name] = true
+	}
+	for name, d := range overrides {
+		if !known[name] {
+			return fmt.Errorf("sidecars: %q is none of the built-in sidecars %v", name, sidecarNames())
+		}
+		if err := d.validate(); err != nil {
+			return fmt.Errorf("sidecars %s: %s", name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package webhook
+
+import (
+	"testing"
+
+	k8s_v1 "k8s.io/api/core/v1"
+)
+
+func Test_sidecarRules(t *testing.T) {
+	if err := validateRules(nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range sidecarRules {
+		if err := r.Match.validate(); err != nil {
+			t.Errorf("%s: %v", r.Name, err)
+		}
+		if err := r.Defaults.validate(); err != nil {
+			t.Errorf("%s: %v", r.Name, err)
+		}
+	}
+}
+
+func TestConfig_Policy_builtinSidecars(t *testing.T) {
+	c, err := ParseConfig([]byte(`
+builtinSidecars: true
+sidecars:
+  linkerd-proxy:
+    containers:
+      limits: {memory: 64Mi}
+rules:
+  - name: team-a
+    match:
+      namespaces: [team-a]
+    defaults:
+      containers: {}
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+	policy := c.Policy(Defaults{Containers: defaults})
+
+	tests := []struct {
+		name          string
+		container     k8s_v1.Container
+		wantRule      string
+		wantLimitsMem string
+	}{
+		{
+			name:          "built-in sidecar precedes the rules",
+			container:     k8s_v1.Container{Name: "istio-proxy", Image: "docker.io/istio/proxyv2:1.20.0"},
+			wantRule:      "sidecar/istio-proxy",
+			wantLimitsMem: "256Mi",
+		},
+		{
+			name:          "overridden sidecar",
+			container:     k8s_v1.Container{Name: "linkerd-proxy", Image: "cr.l5d.io/linkerd/proxy:stable-2.14.0"},
+			wantRule:      "sidecar/linkerd-proxy",
+			wantLimitsMem: "64Mi",
+		},
+		{
+			name:          "sidecar by name and image",
+			container:     k8s_v1.Container{Name: "fluent-bit", Image: "cr.fluentbit.io/fluent/fluent-bit:2.2"},
+			wantRule:      "sidecar/fluent-bit",
+			wantLimitsMem: "128Mi",
+		},
+		{
+			name:      "sidecar name with another image",
+			container: k8s_v1.Container{Name: "cloud-sql-proxy", Image: "example.com/app:1.0"},
+			wantRule:  "team-a",
+		},
+		{
+			name:      "app",
+			container: k8s_v1.Container{Name: "app", Image: "example.com/app:1.0"},
+			wantRule:  "team-a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, d := policy.defaultsFor("team-a", nil, tt.container)
+			if rule != tt.wantRule {
+				t.Fatalf("defaultsFor() rule = %q, want %q", rule, tt.wantRule)
+			}
+			if tt.wantLimitsMem == "" {
+				return
+			}
+			if got := d.Containers.Limits[k8s_v1.ResourceMemory]; got.Cmp(getResourceQuantity(tt.wantLimitsMem)) != 0 {
+				t.Errorf("defaultsFor() limits.memory = %s, want %s", got.String(), tt.wantLimitsMem)
+			}
+		})
+	}
+}
+
+func TestParseConfig_sidecars(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{name: "known sidecar", config: `{"sidecars":{"istio-proxy":{"containers":{}}}}`},
+		{name: "unknown sidecar", config: `{"sidecars":{"envoy":{"containers":{}}}}`, wantErr: true},
+		{name: "invalid sidecar defaults", config: `{"sidecars":{"istio-proxy":{"containers":{"limits":{"cpu":"10m"},"requests":{"cpu":"1"}}}}}`, wantErr: true},
+		{name: "rule named like a sidecar rule", config: `{"rules":[{"name":"sidecar/istio-proxy","defaults":{"containers":{}}}]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseConfig([]byte(tt.config)); (err != nil) != tt.wantErr {
+				t.Errorf("ParseConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
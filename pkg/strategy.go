@@ -0,0 +1,213 @@
+package webhook
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	k8s_v1 "k8s.io/api/core/v1"
+)
+
+// Strategy decides which resource values of a container get filled in from the defaults.
+// @see docs/resource-patch-strategies.md
+type Strategy interface {
+	Apply(c k8s_v1.ResourceRequirements, d k8s_v1.ResourceRequirements) (k8s_v1.ResourceRequirements, error)
+}
+
+// strategies maps the names accepted by the -strategy flag to their implementation.
+var strategies = map[string]Strategy{
+	"complementToDefault": ComplementToDefault{},
+	"complementMemOrCPU":  ComplementMemOrCPU{},
+	"defaultOnEmpty":      DefaultOnEmpty{},
+	"alwaysFillToDefault": AlwaysFillToDefault{},
+}
+
+// StrategyByName returns the Strategy registered under name.
+func StrategyByName(name string) (Strategy, error) {
+	s, found := strategies[name]
+	if !found {
+		return nil, fmt.Errorf("unknown strategy %q, valid strategies are %v", name, StrategyNames())
+	}
+	return s, nil
+}
+
+// StrategyNames returns the sorted names of all known strategies.
+func StrategyNames() []string {
+	names := []string{}
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ComplementToDefault fills each missing cpu/memory request and limit independently with its default.
+type ComplementToDefault struct{}
+
+// Apply implements Strategy.
+func (ComplementToDefault) Apply(c k8s_v1.ResourceRequirements, d k8s_v1.ResourceRequirements) (k8s_v1.ResourceRequirements, error) {
+	return addDefaults(*c.DeepCopy(), d)
+}
+
+// ComplementMemOrCPU only defaults a resource (memory or cpu) the container does not mention at all,
+// e.g. if only memory is set, cpu gets patched and vice versa.
+type ComplementMemOrCPU struct{}
+
+// Apply implements Strategy.
+func (ComplementMemOrCPU) Apply(c k8s_v1.ResourceRequirements, d k8s_v1.ResourceRequirements) (k8s_v1.ResourceRequirements, error) {
+	c = *c.DeepCopy()
+	for _, name := range []k8s_v1.ResourceName{k8s_v1.ResourceMemory, k8s_v1.ResourceCPU} {
+		_, limitFound := c.Limits[name]
+		_, requestFound := c.Requests[name]
+		if limitFound || requestFound {
+			continue
+		}
+		setDefault(&c, d, name)
+	}
+
+	return c, checkRequestsWithinLimits(c)
+}
+
+// DefaultOnEmpty only defaults containers which don't set any cpu/memory request or limit.
+type DefaultOnEmpty struct{}
+
+// Apply implements Strategy.
+func (DefaultOnEmpty) Apply(c k8s_v1.ResourceRequirements, d k8s_v1.ResourceRequirements) (k8s_v1.ResourceRequirements, error) {
+	c = *c.DeepCopy()
+	for _, name := range []k8s_v1.ResourceName{k8s_v1.ResourceMemory, k8s_v1.ResourceCPU} {
+		_, limitFound := c.Limits[name]
+		_, requestFound := c.Requests[name]
+		if limitFound || requestFound {
+			return c, checkRequestsWithinLimits(c)
+		}
+	}
+
+	setDefault(&c, d, k8s_v1.ResourceMemory)
+	setDefault(&c, d, k8s_v1.ResourceCPU)
+
+	return c, nil
+}
+
+// AlwaysFillToDefault fills every missing cpu/memory request and limit.
+// A missing request is set to the containers own limit if there is one (like the api-server does),
+// otherwise to the default request.
+type AlwaysFillToDefault struct{}
+
+// Apply implements Strategy.
+func (AlwaysFillToDefault) Apply(c k8s_v1.ResourceRequirements, d k8s_v1.ResourceRequirements) (k8s_v1.ResourceRequirements, error) {
+	c = *c.DeepCopy()
+	if c.Limits == nil {
+		c.Limits = k8s_v1.ResourceList{}
+	}
+	if c.Requests == nil {
+		c.Requests = k8s_v1.ResourceList{}
+	}
+
+	for _, name := range []k8s_v1.ResourceName{k8s_v1.ResourceMemory, k8s_v1.ResourceCPU} {
+		if _, found := c.Requests[name]; !found {
+			if limit, found := c.Limits[name]; found {
+				c.Requests[name] = limit
+			} else if request, found := d.Requests[name]; found {
+				c.Requests[name] = request
+			}
+		}
+		if _, found := c.Limits[name]; !found {
+			if limit, found := d.Limits[name]; found {
+				c.Limits[name] = limit
+			}
+		}
+	}
+
+	return c, checkRequestsWithinLimits(c)
+}
+
+// setDefault sets the default limit and request of the resource name.
+func setDefault(c *k8s_v1.ResourceRequirements, d k8s_v1.ResourceRequirements, name k8s_v1.ResourceName) {
+	if c.Limits == nil {
+		c.Limits = k8s_v1.ResourceList{}
+	}
+	if c.Requests == nil {
+		c.Requests = k8s_v1.ResourceList{}
+	}
+
+	if limit, found := d.Limits[name]; found {
+		c.Limits[name] = limit
+	}
+	if request, found := d.Requests[name]; found {
+		c.Requests[name] = request
+	}
+}
+
+// checkRequestsWithinLimits returns a requestAboveLimitError if a cpu/memory request is greater than its limit.
+func checkRequestsWithinLimits(c k8s_v1.ResourceRequirements) error {
+	for _, name := range []k8s_v1.ResourceName{k8s_v1.ResourceMemory, k8s_v1.ResourceCPU} {
+		request, requestFound := c.Requests[name]
+		limit, limitFound := c.Limits[name]
+		if requestFound && limitFound && request.Cmp(limit) == 1 {
+			return requestAboveLimitError{name: name}
+		}
+	}
+
+	return nil
+}
+
+// requestAboveLimitError is returned by strategies for a request greater than its limit.
+type requestAboveLimitError struct {
+	name k8s_v1.ResourceName
+}
+
+func (e requestAboveLimitError) Error() string {
+	return fmt.Sprintf("requested %s is greater than %s limit", e.name, e.name)
+}
+
+// Derive wraps a Strategy and first derives missing values from the ones the container sets itself:
+// a missing limit becomes the containers request and a missing request becomes the containers limit.
+// Only values still missing afterwards are left to the wrapped Strategy.
+type Derive struct {
+	Strategy Strategy
+}
+
+// Apply implements Strategy.
+func (s Derive) Apply(c k8s_v1.ResourceRequirements, d k8s_v1.ResourceRequirements) (k8s_v1.ResourceRequirements, error) {
+	c = *c.DeepCopy()
+	for _, name := range []k8s_v1.ResourceName{k8s_v1.ResourceMemory, k8s_v1.ResourceCPU} {
+		limit, limitFound := c.Limits[name]
+		request, requestFound := c.Requests[name]
+		if requestFound && !limitFound {
+			if c.Limits == nil {
+				c.Limits = k8s_v1.ResourceList{}
+			}
+			c.Limits[name] = request
+		}
+		if limitFound && !requestFound {
+			if c.Requests == nil {
+				c.Requests = k8s_v1.ResourceList{}
+			}
+			c.Requests[name] = limit
+		}
+	}
+
+	return s.Strategy.Apply(c, d)
+}
+
+// guarantee sets the cpu/memory requests of c to their limits, failing for a missing limit.
+func guarantee(c k8s_v1.ResourceRequirements) (k8s_v1.ResourceRequirements, error) {
+	c = *c.DeepCopy()
+	missing := []string{}
+	for _, name := range []k8s_v1.ResourceName{k8s_v1.ResourceMemory, k8s_v1.ResourceCPU} {
+		limit, found := c.Limits[name]
+		if !found {
+			missing = append(missing, string(name))
+			continue
+		}
+		if c.Requests == nil {
+			c.Requests = k8s_v1.ResourceList{}
+		}
+		c.Requests[name] = limit.DeepCopy()
+	}
+	if len(missing) > 0 {
+		return c, fmt.Errorf("can't get Guaranteed QoS without %s limit", strings.Join(missing, " and "))
+	}
+
+	return c, nil
+}
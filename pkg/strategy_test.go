@@ -0,0 +1,187 @@
+package webhook
+
+import (
+	"reflect"
+	"testing"
+
+	k8s_v1 "k8s.io/api/core/v1"
+)
+
+func TestStrategyByName(t *testing.T) {
+	for _, name := range StrategyNames() {
+		if _, err := StrategyByName(name); err != nil {
+			t.Errorf("StrategyByName(%q) error = %v", name, err)
+		}
+	}
+	if _, err := StrategyByName("unknown"); err == nil {
+		t.Errorf("StrategyByName(%q) expected error", "unknown")
+	}
+}
+
+func TestStrategy_Apply(t *testing.T) {
+	type args struct {
+		c k8s_v1.ResourceRequirements
+		d k8s_v1.ResourceRequirements
+	}
+	tests := []struct {
+		name     string
+		strategy Strategy
+		args     args
+		want     k8s_v1.ResourceRequirements
+		wantErr  bool
+	}{
+		{
+			name:     "complementToDefault fills each missing value",
+			strategy: ComplementToDefault{},
+			args: args{
+				c: parseTestResourceRequirements("2G", "", "", ""),
+				d: defaults,
+			},
+			want: parseTestResourceRequirements("2G", limitCPU, requestMemory, requestCPU),
+		},
+		{
+			name:     "complementMemOrCPU empty ResourceRequirements sets default",
+			strategy: ComplementMemOrCPU{},
+			args: args{
+				c: k8s_v1.ResourceRequirements{},
+				d: defaults,
+			},
+			want: defaults,
+		},
+		{
+			name:     "complementMemOrCPU Limits mem only patches cpu",
+			strategy: ComplementMemOrCPU{},
+			args: args{
+				c: parseTestResourceRequirements("2G", "", "", ""),
+				d: defaults,
+			},
+			want: parseTestResourceRequirements("2G", limitCPU, "", requestCPU),
+		},
+		{
+			name:     "complementMemOrCPU Requests cpu only patches mem",
+			strategy: ComplementMemOrCPU{},
+			args: args{
+				c: parseTestResourceRequirements("", "", "", "0.05"),
+				d: defaults,
+			},
+			want: parseTestResourceRequirements(limitMemory, "", requestMemory, "0.05"),
+		},
+		{
+			name:     "complementMemOrCPU mem & cpu set patches nothing",
+			strategy: ComplementMemOrCPU{},
+			args: args{
+				c: parseTestResourceRequirements("2G", "", "", "0.05"),
+				d: defaults,
+			},
+			want: parseTestResourceRequirements("2G", "", "", "0.05"),
+		},
+		{
+			name:     "defaultOnEmpty empty ResourceRequirements sets default",
+			strategy: DefaultOnEmpty{},
+			args: args{
+				c: k8s_v1.ResourceRequirements{},
+				d: defaults,
+			},
+			want: defaults,
+		},
+		{
+			name:     "defaultOnEmpty anything set patches nothing",
+			strategy: DefaultOnEmpty{},
+			args: args{
+				c: parseTestResourceRequirements("2G", "", "", ""),
+				d: defaults,
+			},
+			want: parseTestResourceRequirements("2G", "", "", ""),
+		},
+		{
+			name:     "defaultOnEmpty get error on requested mem greater than limit",
+			strategy: DefaultOnEmpty{},
+			args: args{
+				c: parseTestResourceRequirements("1G", "", "2G", ""),
+				d: defaults,
+			},
+			want:    parseTestResourceRequirements("1G", "", "2G", ""),
+			wantErr: true,
+		},
+		{
+			name:     "alwaysFillToDefault empty ResourceRequirements sets default",
+			strategy: AlwaysFillToDefault{},
+			args: args{
+				c: k8s_v1.ResourceRequirements{},
+				d: defaults,
+			},
+			want: defaults,
+		},
+		{
+			name:     "alwaysFillToDefault Limits mem sets Requests mem to same",
+			strategy: AlwaysFillToDefault{},
+			args: args{
+				c: parseTestResourceRequirements("512M", "", "", ""),
+				d: defaults,
+			},
+			want: parseTestResourceRequirements("512M", limitCPU, "512M", requestCPU),
+		},
+		{
+			name:     "alwaysFillToDefault get error on to low CPU limit",
+			strategy: AlwaysFillToDefault{},
+			args: args{
+				c: parseTestResourceRequirements("", "", "", "1"),
+				d: defaults,
+			},
+			want:    parseTestResourceRequirements(limitMemory, limitCPU, requestMemory, "1"),
+			wantErr: true,
+		},
+		{
+			name:     "derive Requests mem greater than default limit mem sets Limits mem to same",
+			strategy: Derive{Strategy: ComplementToDefault{}},
+			args: args{
+				c: parseTestResourceRequirements("", "", "2Gi", ""),
+				d: defaults,
+			},
+			want: parseTestResourceRequirements("2Gi", limitCPU, "2Gi", requestCPU),
+		},
+		{
+			name:     "derive Limits cpu sets Requests cpu to same",
+			strategy: Derive{Strategy: ComplementToDefault{}},
+			args: args{
+				c: parseTestResourceRequirements("", "0.05", "", ""),
+				d: defaults,
+			},
+			want: parseTestResourceRequirements(limitMemory, "0.05", requestMemory, "0.05"),
+		},
+		{
+			name:     "derive empty ResourceRequirements sets default",
+			strategy: Derive{Strategy: ComplementToDefault{}},
+			args: args{
+				c: k8s_v1.ResourceRequirements{},
+				d: defaults,
+			},
+			want: defaults,
+		},
+		{
+			name:     "derive with complementMemOrCPU counts derived resource as set",
+			strategy: Derive{Strategy: ComplementMemOrCPU{}},
+			args: args{
+				c: parseTestResourceRequirements("", "", "2Gi", ""),
+				d: defaults,
+			},
+			want: parseTestResourceRequirements("2Gi", limitCPU, "2Gi", requestCPU),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := tt.args.c.DeepCopy()
+			got, err := tt.strategy.Apply(tt.args.c, tt.args.d)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Apply() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(tt.args.c, *original) {
+				t.Errorf("Apply() modified its input to %v", tt.args.c)
+			}
+		})
+	}
+}
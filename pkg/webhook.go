@@ -4,13 +4,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"path/filepath"
-	"strconv"
+	"strings"
 
 	"github.com/sirupsen/logrus"
+	admission_v1 "k8s.io/api/admission/v1"
 	"k8s.io/api/admission/v1beta1"
 	k8s_v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	corev1listers "k8s.io/client-go/listers/core/v1"
 )
 
 // Patch represents a single JSONPatch operation
@@ -21,28 +23,175 @@ type Patch struct {
 	Value interface{} `json:"value"`
 }
 
+// Defaults holds the default resources per kind of container.
+type Defaults struct {
+	// Containers are used for regular containers and native sidecars (restartable init containers).
+	Containers k8s_v1.ResourceRequirements `json:"containers"`
+	// InitContainers are used for init containers, nil uses Containers.
+	InitContainers *k8s_v1.ResourceRequirements `json:"initContainers,omitempty"`
+	// Min and Max bound the requests and limits of all kinds of containers, after defaulting.
+	Min k8s_v1.ResourceList `json:"min,omitempty"`
+	Max k8s_v1.ResourceList `json:"max,omitempty"`
+	// Clamp sets values out of bounds to the bound, otherwise the pod gets denied.
+	Clamp bool `json:"clamp,omitempty"`
+	// MaxLimitRequestRatio caps the limit of a resource to this multiple of its request.
+	MaxLimitRequestRatio k8s_v1.ResourceList `json:"maxLimitRequestRatio,omitempty"`
+	// RaiseRequests raises requests to limit / ratio when the ratio is exceeded, otherwise the pod gets denied.
+	RaiseRequests bool `json:"raiseRequests,omitempty"`
+	// RequestAboveLimit fixes a request greater than its limit by raising the limit (raiseLimit)
+	// or lowering the request (lowerRequest), empty denies the pod.
+	RequestAboveLimit string `json:"requestAboveLimit,omitempty"`
+	// QoS Guaranteed sets the cpu/memory requests of containers and init containers to their limits,
+	// denying pods where a limit is missing.
+	QoS string `json:"qos,omitempty"`
+	// CPULimit none never adds cpu limits, strip also removes the cpu limits containers set. Memory limits are kept.
+	CPULimit string `json:"cpuLimit,omitempty"`
+	// LimitFactors replace the default limit of a resource with its request times the factor,
+	// for defaulted and container set requests alike.
+	LimitFactors k8s_v1.ResourceList `json:"limitFactors,omitempty"`
+}
+
+// defaultsFunc returns the defaults and their source for container c of the PodSpec s,
+// an error denies the pod.
+type defaultsFunc func(s podSpec, c k8s_v1.Container) (defaultsSource, Defaults, error)
+
+// defaultedContainer is a container with defaulted resources and the rule providing the defaults.
+type defaultedContainer struct {
+	Name string
+	Rule string
+}
+
+// qosGuaranteed is the value of Defaults.QoS making pods Guaranteed.
+const qosGuaranteed = string(k8s_v1.PodQOSGuaranteed)
+
+// supportedReviewVersions are the AdmissionReview apiVersions Mutate understands.
+// Both share the same schema, so they get decoded into admission/v1 and answered in the apiVersion they came in.
+var supportedReviewVersions = map[string]bool{
+	admission_v1.SchemeGroupVersion.String(): true,
+	v1beta1.SchemeGroupVersion.String():      true,
+}
+
+// ephemeralContainersSubResource is the pod subresource `kubectl debug` adds ephemeral containers through.
+// They can't be defaulted, since the api-server rejects resources on ephemeral containers.
+const ephemeralContainersSubResource = "ephemeralcontainers"
+
+// Mutator holds everything needed to answer the webhook requests.
+type Mutator struct {
+	Policy   Policy
+	Strategy Strategy
+	// PodSpecPaths locates the PodSpecs of custom resources.
+	PodSpecPaths []PodSpecPath
+	// Config, if set, provides the Policy and PodSpecPaths of the reloaded config file instead of the fields above.
+	Config *ConfigWatcher
+	// Policies, if set, provides the policy custom resources as rules preceding the ones of the Policy.
+	Policies *PolicyController
+	// Namespaces looks up the namespace annotations overriding the container defaults, nil disables the overrides.
+	Namespaces corev1listers.NamespaceLister
+	// ProfileNamespaces, if set, enables the opt-in mode: only pods in namespaces labeled
+	// default-resources-webhook: <profile> get defaulted, by the profile named by the label.
+	ProfileNamespaces corev1listers.NamespaceLister
+	// DryRun always returns a success AdmissionReview.
+	DryRun bool
+}
+
 // Mutate responds to kubernetes webhooks request to add resource limits.
-func Mutate(w http.ResponseWriter, r *http.Request, defaults k8s_v1.ResourceRequirements, dryRun bool) error {
+func (m *Mutator) Mutate(w http.ResponseWriter, r *http.Request) error {
 
-	in := &v1beta1.AdmissionReview{}
+	in := &admission_v1.AdmissionReview{}
 	err := json.NewDecoder(r.Body).Decode(in)
 	if err != nil {
 		return fmt.Errorf("failed to json decode body: %s", err)
 	}
+	if !supportedReviewVersions[in.APIVersion] {
+		return fmt.Errorf("unsupported AdmissionReview apiVersion %q", in.APIVersion)
+	}
+	if in.Request == nil {
+		return fmt.Errorf("AdmissionReview contains no request")
+	}
 
-	pod := k8s_v1.Pod{}
-	if err := json.Unmarshal(in.Request.Object.Raw, &pod); err != nil {
-		return fmt.Errorf("failed to Unmarshal Pod from incoming AdmissionReview: %s", err)
+	policy, podSpecPaths := m.Policy, m.PodSpecPaths
+	if m.Config != nil {
+		var c Config
+		c, policy = m.Config.Load()
+		podSpecPaths = c.PodSpecPaths
+	}
+	if m.Policies != nil {
+		policy.Rules = append(m.Policies.Rules(in.Request.Namespace), policy.Rules...)
 	}
 
-	resp, err := createResponse(pod.Spec.Containers, defaults)
+	optedIn := true
+	overrides, warnings := k8s_v1.ResourceRequirements{}, []string{}
+	if m.ProfileNamespaces != nil {
+		var warning string
+		policy, optedIn, warning = policy.optIn(getNamespace(m.ProfileNamespaces, in.Request.Namespace))
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+	if ns := getNamespace(m.Namespaces, in.Request.Namespace); ns != nil {
+		var nsWarnings []string
+		overrides, nsWarnings = namespaceOverrides(ns)
+		warnings = append(warnings, nsWarnings...)
+	}
+
+	overridden := map[string]string{}
+	for _, field := range resourceFields(overrides) {
+		overridden[field] = "Namespace/" + in.Request.Namespace
+	}
+	defaultsFor := func(s podSpec, c k8s_v1.Container) (defaultsSource, Defaults, error) {
+		rule, d, err := policy.defaultsForPod(in.Request.Namespace, s, c)
+		if err != nil {
+			return defaultsSource{}, Defaults{}, err
+		}
+		d.Containers = overrideDefaults(d.Containers, overrides)
+		return defaultsSource{Rule: rule, Overrides: overridden, Skipped: policy.skips(s, c)}, d, nil
+	}
+
+	var resp *admission_v1.AdmissionResponse
+	var defaulted []defaultedContainer
+	if !optedIn || in.Request.SubResource == ephemeralContainersSubResource {
+		resp = &admission_v1.AdmissionResponse{Allowed: true}
+	} else {
+		gvk := schema.GroupVersionKind{Group: in.Request.Kind.Group, Version: in.Request.Kind.Version, Kind: in.Request.Kind.Kind}
+		var owned bool
+		owned, err = ownedByTemplateOwner(gvk, in.Request.Object.Raw)
+		if err != nil {
+			return fmt.Errorf("failed to check owners in incoming AdmissionReview: %s", err)
+		}
+		var specs []podSpec
+		if !owned {
+			specs, err = findPodSpecs(gvk, in.Request.Object.Raw, podSpecPaths)
+			if err != nil {
+				return fmt.Errorf("failed to find PodSpecs in incoming AdmissionReview: %s", err)
+			}
+		}
+		if len(specs) == 0 && !owned {
+			logrus.WithFields(logrus.Fields{
+				"kind": in.Request.Kind,
+			}).Warn("no PodSpec found to mutate")
+		}
+		var hash string
+		hash, err = policy.hash(overrides)
+		if err != nil {
+			return fmt.Errorf("failed to hash policy: %s", err)
+		}
+		// the object being created carries the marker of a reinvocation's first pass, the one inherited
+		// from a pod template defaulted with the same policy and overrides, or one copied by its author.
+		// So the marker only keeps the values of listed containers, missing ones are still defaulted
+		// and the constraints still checked.
+		marker := appliedMarker{Hash: hash, Reentry: in.Request.Operation == admission_v1.Create}
+		resp, defaulted, err = createResponse(specs, defaultsFor, m.Strategy, marker)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create response: %s", err)
 	}
+	resp.Warnings = append(warnings, resp.Warnings...)
 
 	resp.UID = in.Request.UID
-	patchType := v1beta1.PatchTypeJSONPatch
-	resp.PatchType = &patchType
+	if resp.Patch != nil {
+		patchType := admission_v1.PatchTypeJSONPatch
+		resp.PatchType = &patchType
+	}
 
 	if resp.Result != nil && resp.Result.Status == metav1.StatusFailure {
 		logrus.WithFields(logrus.Fields{
@@ -50,16 +199,28 @@ func Mutate(w http.ResponseWriter, r *http.Request, defaults k8s_v1.ResourceRequ
 		}).Warn("failed AdmissionResponse")
 	}
 
-	out := v1beta1.AdmissionReview{Response: resp}
+	out := admission_v1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: in.APIVersion,
+			Kind:       "AdmissionReview",
+		},
+		Response: resp,
+	}
+
+	// server-side dry-runs aren't counted, the webhook declares no side effects for them
+	serverDryRun := in.Request.DryRun != nil && *in.Request.DryRun
+	if m.Policies != nil && !m.DryRun && !serverDryRun && resp.Allowed {
+		m.Policies.Defaulted(defaultedRules(defaulted))
+	}
 
-	if dryRun {
+	if m.DryRun {
 		logrus.WithFields(logrus.Fields{
-			"dryRun":              dryRun,
+			"dryRun":              m.DryRun,
 			"in_AdmissionReview":  in,
 			"out_AdmissionReview": out,
 		}).Info("DRY-RUN: supposed AdmissionReview")
 
-		out.Response = &v1beta1.AdmissionResponse{Allowed: true}
+		out.Response = &admission_v1.AdmissionResponse{UID: in.Request.UID, Allowed: true}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -76,36 +237,228 @@ func Mutate(w http.ResponseWriter, r *http.Request, defaults k8s_v1.ResourceRequ
 	return nil
 }
 
-func createResponse(cc []k8s_v1.Container, defaults k8s_v1.ResourceRequirements) (*v1beta1.AdmissionResponse, error) {
+func createResponse(specs []podSpec, defaultsFor defaultsFunc, strategy Strategy, marker appliedMarker) (*admission_v1.AdmissionResponse, []defaultedContainer, error) {
 
-	resp := &v1beta1.AdmissionResponse{}
-	patches := []Patch{}
-	for i, c := range cc {
-		r, err := addDefaults(c.Resources, defaults)
+	m := &mutation{strategy: strategy}
+	for _, s := range specs {
+		patched := len(m.patches)
+		applied := marker.appliedContainers(s)
+		rec := newPodRecord()
+		if len(applied) > 0 {
+			// keep the record of the earlier pass
+			rec = loadPodRecord(s)
+		}
+		for i, c := range s.Spec.Containers {
+			source, d, err := defaultsFor(s, c)
+			if err != nil {
+				m.denials = append(m.denials, containerDenial(c, err))
+				continue
+			}
+			if source.Skipped || applied[c.Name] && !m.adds(c, d.Containers, d) {
+				m.skipped(c, d)
+				continue
+			}
+			applied[c.Name] = true
+			m.container(s, "containers", i, c, d.Containers, d, source, rec)
+		}
+		for i, c := range s.Spec.InitContainers {
+			source, d, err := defaultsFor(s, c)
+			if err != nil {
+				m.denials = append(m.denials, containerDenial(c, err))
+				continue
+			}
+			r := d.Containers
+			if d.InitContainers != nil && !isSidecar(c) {
+				r = *d.InitContainers
+				// overrides only replace the container defaults
+				source.Overrides = nil
+			}
+			if source.Skipped || applied[c.Name] && !m.adds(c, r, d) {
+				m.skipped(c, d)
+				continue
+			}
+			applied[c.Name] = true
+			m.container(s, "initContainers", i, c, r, d, source, rec)
+		}
+		if len(m.patches) == patched {
+			continue
+		}
+
+		annotations, err := rec.annotations()
 		if err != nil {
-			resp.Allowed = false
-			resp.Result = &metav1.Status{
-				Message: err.Error(),
-				Status:  metav1.StatusFailure,
+			return nil, nil, err
+		}
+		if marker.Hash != "" {
+			for key, value := range marker.annotations(applied) {
+				annotations[key] = value
 			}
-			return resp, nil
 		}
-		patches = append(patches, Patch{
-			Op:    "replace",
-			Path:  filepath.Join("/spec/containers", strconv.Itoa(i), "resources"),
-			Value: r,
-		})
+		m.patches = append(m.patches, annotationPatches(s, annotations)...)
+	}
+
+	return m.response()
+}
+
+// mutation collects the patches, warnings and denials for the containers of an admitted object.
+type mutation struct {
+	strategy  Strategy
+	patches   []Patch
+	warnings  []string
+	denials   []string
+	defaulted []defaultedContainer
+}
+
+// container adds the patches for the resources of the container c at index i of the containers of kind in s,
+// defaulted from defaults and constrained by the profile p of the rule, and records the changes in rec.
+func (m *mutation) container(s podSpec, kind string, i int, c k8s_v1.Container, defaults k8s_v1.ResourceRequirements, p Defaults, source defaultsSource, rec *podRecord) {
+	r, fixed, err := resolveResources(c.Resources, defaults, p, m.strategy)
+	if err != nil {
+		m.denials = append(m.denials, containerDenial(c, err))
+		return
+	}
+
+	patches := containerPatches(s, kind, i, c, r)
+	m.patches = append(m.patches, patches...)
+	if len(patches) > 0 {
+		m.warnings = append(m.warnings, defaultedWarning(c))
+		m.defaulted = append(m.defaulted, defaultedContainer{Name: c.Name, Rule: source.Rule})
+		rec.add(c, r, source, fixed)
 	}
+}
 
-	json, err := json.Marshal(patches)
+// adds reports whether defaulting adds resources to the container c, which the marker of an earlier pass doesn't skip:
+// markers are inherited from pod templates and can be copied by pod authors, so they only keep the values set since.
+func (m *mutation) adds(c k8s_v1.Container, defaults k8s_v1.ResourceRequirements, p Defaults) bool {
+	if p.CPULimit != "" {
+		defaults = withoutCPULimit(defaults)
+	}
+	r, err := m.strategy.Apply(c.Resources, p.withoutFactorLimits(defaults))
 	if err != nil {
-		return nil, fmt.Errorf("failed to encode patch: %s", err)
+		return true
+	}
+	r = p.applyLimitFactors(c.Resources, r)
+	for _, l := range []struct{ old, new k8s_v1.ResourceList }{
+		{old: c.Resources.Limits, new: r.Limits},
+		{old: c.Resources.Requests, new: r.Requests},
+	} {
+		for name := range l.new {
+			if _, found := l.old[name]; !found {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// skipped denies the container c opted out of defaulting if its resources violate the constraints of p.
+func (m *mutation) skipped(c k8s_v1.Container, p Defaults) {
+	if err := p.checkConstraints(c.Resources); err != nil {
+		m.denials = append(m.denials, containerDenial(c, err))
+	}
+}
+
+// response denies the object if any container got denied, otherwise it allows the object with the patches.
+func (m *mutation) response() (*admission_v1.AdmissionResponse, []defaultedContainer, error) {
+	resp := &admission_v1.AdmissionResponse{Warnings: m.warnings}
+	if len(m.denials) > 0 {
+		return deny(resp, fmt.Errorf("%s", strings.Join(m.denials, "; "))), nil, nil
 	}
 
 	resp.Allowed = true
+	if len(m.patches) == 0 {
+		return resp, m.defaulted, nil
+	}
+	json, err := json.Marshal(m.patches)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode patch: %s", err)
+	}
 	resp.Patch = []byte(json)
 
-	return resp, nil
+	return resp, m.defaulted, nil
+}
+
+// resolveResources returns the resources c after applying the strategy and the constraints of the profile p,
+// together with the fixes of requests above their limit.
+func resolveResources(c k8s_v1.ResourceRequirements, defaults k8s_v1.ResourceRequirements, p Defaults, strategy Strategy) (k8s_v1.ResourceRequirements, []string, error) {
+	if p.CPULimit == cpuLimitStrip {
+		c = withoutCPULimit(c)
+	}
+	if p.CPULimit != "" {
+		defaults = withoutCPULimit(defaults)
+	}
+	defaults = p.withoutFactorLimits(defaults)
+	r, err := strategy.Apply(c, defaults)
+	fixes := []string{}
+	if _, aboveLimit := err.(requestAboveLimitError); aboveLimit && p.RequestAboveLimit != "" {
+		r, fixes = p.fixRequestsAboveLimits(r)
+		err = nil
+	}
+	if err != nil {
+		return k8s_v1.ResourceRequirements{}, nil, err
+	}
+	r = p.applyLimitFactors(c, r)
+	// strategies like Derive add limits on their own
+	if _, found := c.Limits[k8s_v1.ResourceCPU]; !found && p.CPULimit != "" {
+		r = withoutCPULimit(r)
+	}
+	// after fixing requests above their limit, so a raised limit is the request as well
+	if p.QoS == qosGuaranteed {
+		r, err = guarantee(r)
+		if err != nil {
+			return k8s_v1.ResourceRequirements{}, nil, err
+		}
+	}
+	r, err = p.enforceBounds(r)
+	if err != nil {
+		return k8s_v1.ResourceRequirements{}, nil, err
+	}
+	// after the bounds, a raised request can't exceed its limit, so it stays within them
+	r, err = p.enforceLimitRequestRatio(r)
+	if err != nil {
+		return k8s_v1.ResourceRequirements{}, nil, err
+	}
+
+	return r, fixes, nil
+}
+
+// containerDenial prefixes the reason err to deny a pod with the container c.
+func containerDenial(c k8s_v1.Container, err error) string {
+	return fmt.Sprintf("container %q: %s", c.Name, err)
+}
+
+// defaultedRules returns the distinct rules which defaulted the containers.
+func defaultedRules(defaulted []defaultedContainer) []string {
+	seen := map[string]bool{}
+	rules := []string{}
+	for _, d := range defaulted {
+		if !seen[d.Rule] {
+			seen[d.Rule] = true
+			rules = append(rules, d.Rule)
+		}
+	}
+
+	return rules
+}
+
+// defaultedWarning is returned to the client (e.g. shown by kubectl) for every container with defaulted resources.
+func defaultedWarning(c k8s_v1.Container) string {
+	return fmt.Sprintf("default-container-resources: defaulted resources of container %q", c.Name)
+}
+
+// isSidecar reports whether the init container c is a native sidecar,
+// which keeps running alongside the regular containers.
+func isSidecar(c k8s_v1.Container) bool {
+	return c.RestartPolicy != nil && *c.RestartPolicy == k8s_v1.ContainerRestartPolicyAlways
+}
+
+func deny(resp *admission_v1.AdmissionResponse, err error) *admission_v1.AdmissionResponse {
+	resp.Allowed = false
+	resp.Result = &metav1.Status{
+		Message: err.Error(),
+		Status:  metav1.StatusFailure,
+	}
+	return resp
 }
 
 func addDefaults(c k8s_v1.ResourceRequirements, d k8s_v1.ResourceRequirements) (k8s_v1.ResourceRequirements, error) {
@@ -117,30 +470,17 @@ func addDefaults(c k8s_v1.ResourceRequirements, d k8s_v1.ResourceRequirements) (
 		c.Requests = k8s_v1.ResourceList{}
 	}
 
-	if _, found := c.Limits[k8s_v1.ResourceMemory]; !found {
-		c.Limits[k8s_v1.ResourceMemory] = d.Limits[k8s_v1.ResourceMemory]
-	}
-	if _, found := c.Limits[k8s_v1.ResourceCPU]; !found {
-		c.Limits[k8s_v1.ResourceCPU] = d.Limits[k8s_v1.ResourceCPU]
-	}
-	if _, found := c.Requests[k8s_v1.ResourceMemory]; !found {
-		c.Requests[k8s_v1.ResourceMemory] = d.Requests[k8s_v1.ResourceMemory]
-	}
-	if _, found := c.Requests[k8s_v1.ResourceCPU]; !found {
-		c.Requests[k8s_v1.ResourceCPU] = d.Requests[k8s_v1.ResourceCPU]
-	}
-
-	requestMem := c.Requests[k8s_v1.ResourceMemory]
-	limitMem := c.Limits[k8s_v1.ResourceMemory]
-	if requestMem.Cmp(limitMem) == 1 {
-		return c, fmt.Errorf("requested memory is greater than memory limit")
+	// defaults of a rule don't have to be complete, only the given ones get filled in
+	for name, q := range d.Limits {
+		if _, found := c.Limits[name]; !found {
+			c.Limits[name] = q
+		}
 	}
-
-	requestCPU := c.Requests[k8s_v1.ResourceCPU]
-	limitCPU := c.Limits[k8s_v1.ResourceCPU]
-	if requestCPU.Cmp(limitCPU) == 1 {
-		return c, fmt.Errorf("requested cpu is greater than cpu limit")
+	for name, q := range d.Requests {
+		if _, found := c.Requests[name]; !found {
+			c.Requests[name] = q
+		}
 	}
 
-	return c, nil
+	return c, checkRequestsWithinLimits(c)
 }
@@ -1,9 +1,16 @@
 package webhook
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 
+	jsonpatch "github.com/evanphx/json-patch"
+	admission_v1 "k8s.io/api/admission/v1"
 	k8s_v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 )
@@ -204,3 +211,274 @@ func Test_addDefaults(t *testing.T) {
 		})
 	}
 }
+
+func Test_createResponse(t *testing.T) {
+	always := k8s_v1.ContainerRestartPolicyAlways
+	initDefaults := parseTestResourceRequirements("512M", "0.5", "256M", "0.05")
+
+	tests := []struct {
+		name        string
+		spec        k8s_v1.PodSpec
+		wantAllowed bool
+		want        map[string]k8s_v1.ResourceRequirements
+	}{
+		{
+			name: "containers and init containers get their own defaults",
+			spec: k8s_v1.PodSpec{
+				Containers:     []k8s_v1.Container{{Name: "app"}},
+				InitContainers: []k8s_v1.Container{{Name: "migrate"}},
+			},
+			wantAllowed: true,
+			want: map[string]k8s_v1.ResourceRequirements{
+				"/spec/containers/0/resources":     defaults,
+				"/spec/initContainers/0/resources": initDefaults,
+			},
+		},
+		{
+			name: "native sidecar gets container defaults",
+			spec: k8s_v1.PodSpec{
+				Containers: []k8s_v1.Container{{Name: "app"}},
+				InitContainers: []k8s_v1.Container{
+					{Name: "migrate"},
+					{Name: "proxy", RestartPolicy: &always},
+				},
+			},
+			wantAllowed: true,
+			want: map[string]k8s_v1.ResourceRequirements{
+				"/spec/containers/0/resources":     defaults,
+				"/spec/initContainers/0/resources": initDefaults,
+				"/spec/initContainers/1/resources": defaults,
+			},
+		},
+		{
+			name: "init container requesting more than its limit is denied",
+			spec: k8s_v1.PodSpec{
+				Containers: []k8s_v1.Container{{Name: "app"}},
+				InitContainers: []k8s_v1.Container{
+					{Name: "migrate", Resources: parseTestResourceRequirements("", "", "1G", "")},
+				},
+			},
+			wantAllowed: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := podSpec{Path: "/spec", Spec: tt.spec}
+			resp, _, err := createResponse([]podSpec{s}, staticDefaults(Defaults{Containers: defaults, InitContainers: &initDefaults}), ComplementToDefault{}, appliedMarker{})
+			if err != nil {
+				t.Fatalf("createResponse() error = %v", err)
+			}
+			if resp.Allowed != tt.wantAllowed {
+				t.Fatalf("createResponse() Allowed = %v, want %v", resp.Allowed, tt.wantAllowed)
+			}
+			if !tt.wantAllowed {
+				return
+			}
+
+			doc := testObject(t, s)
+			got := patchedTestResources(t, s.Path, doc, applyTestPatch(t, doc, resp.Patch))
+			if len(got) != len(tt.want) {
+				t.Errorf("createResponse() patched %v, want %v", got, tt.want)
+			}
+			for path, want := range tt.want {
+				if !equalResources(got[path], want) {
+					t.Errorf("createResponse() %s = %v, want %v", path, got[path], want)
+				}
+			}
+		})
+	}
+}
+
+// staticDefaults returns d for every container.
+func staticDefaults(d Defaults) defaultsFunc {
+	return func(s podSpec, c k8s_v1.Container) (defaultsSource, Defaults, error) {
+		return defaultsSource{Rule: defaultRuleName}, d, nil
+	}
+}
+
+// decodeTestResponsePatch applies the patch of an encoded AdmissionReview to the pod object
+// and returns the changed resources of its containers by path.
+func decodeTestResponsePatch(t *testing.T, object string, body []byte) map[string]k8s_v1.ResourceRequirements {
+	out := admission_v1.AdmissionReview{}
+	if err := json.Unmarshal(body, &out); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return patchedTestResources(t, "/spec", []byte(object), applyTestPatch(t, []byte(object), out.Response.Patch))
+}
+
+// testObject encodes an object with the PodSpec s at its path and, unless s.NoMeta, the metadata next to it.
+func testObject(t *testing.T, s podSpec) []byte {
+	doc := map[string]interface{}{}
+	setTestPointer(t, doc, s.Path, s.Spec)
+	if !s.NoMeta {
+		setTestPointer(t, doc, metadataPath(s.Path), s.Meta)
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("failed to encode object: %v", err)
+	}
+	return b
+}
+
+// setTestPointer sets the JSON pointer p within doc to v, creating missing objects along the way.
+func setTestPointer(t *testing.T, doc map[string]interface{}, p string, v interface{}) {
+	tokens := strings.Split(strings.TrimPrefix(p, "/"), "/")
+	for _, token := range tokens[:len(tokens)-1] {
+		child, ok := doc[token].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			doc[token] = child
+		}
+		doc = child
+	}
+	doc[tokens[len(tokens)-1]] = v
+}
+
+// applyTestPatch applies the JSON patch to doc, no patch leaves doc as it is.
+func applyTestPatch(t *testing.T, doc []byte, patch []byte) []byte {
+	if patch == nil {
+		return doc
+	}
+	p, err := jsonpatch.DecodePatch(patch)
+	if err != nil {
+		t.Fatalf("failed to decode patch: %v", err)
+	}
+	patched, err := p.Apply(doc)
+	if err != nil {
+		t.Fatalf("failed to apply patch %s: %v", patch, err)
+	}
+	return patched
+}
+
+// patchedTestResources returns the resources of the containers of the PodSpec at specPath,
+// which differ between doc and patched, by path.
+func patchedTestResources(t *testing.T, specPath string, doc []byte, patched []byte) map[string]k8s_v1.ResourceRequirements {
+	decode := func(b []byte) k8s_v1.PodSpec {
+		var v interface{}
+		if err := json.Unmarshal(b, &v); err != nil {
+			t.Fatalf("failed to decode object: %v", err)
+		}
+		spec := k8s_v1.PodSpec{}
+		if s, found := lookupPointer(v, specPath); found {
+			b, _ := json.Marshal(s)
+			if err := json.Unmarshal(b, &spec); err != nil {
+				t.Fatalf("failed to decode PodSpec: %v", err)
+			}
+		}
+		return spec
+	}
+	old, new := decode(doc), decode(patched)
+
+	got := map[string]k8s_v1.ResourceRequirements{}
+	changed := func(kind string, i int, a, b k8s_v1.ResourceRequirements) {
+		if !equalResources(a, b) {
+			got[specPath+"/"+kind+"/"+strconv.Itoa(i)+"/resources"] = b
+		}
+	}
+	for i := range new.Containers {
+		changed("containers", i, old.Containers[i].Resources, new.Containers[i].Resources)
+	}
+	for i := range new.InitContainers {
+		changed("initContainers", i, old.InitContainers[i].Resources, new.InitContainers[i].Resources)
+	}
+	return got
+}
+
+// equalResources compares quantities by value, since quantities decoded from a patch differ in their internal format.
+func equalResources(a, b k8s_v1.ResourceRequirements) bool {
+	return equalResourceList(a.Limits, b.Limits) && equalResourceList(a.Requests, b.Requests)
+}
+
+func equalResourceList(a, b k8s_v1.ResourceList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, q := range a {
+		other, found := b[name]
+		if !found || q.Cmp(other) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMutate(t *testing.T) {
+	pod := `{"metadata":{"name":"nginx","namespace":"foo"},"spec":{"containers":[{"name":"nginx","image":"nginx:1.7.9"}]}}`
+	tests := []struct {
+		name       string
+		apiVersion string
+		wantErr    bool
+	}{
+		{name: "admission.k8s.io/v1 is answered in v1", apiVersion: "admission.k8s.io/v1"},
+		{name: "admission.k8s.io/v1beta1 is answered in v1beta1", apiVersion: "admission.k8s.io/v1beta1"},
+		{name: "unknown apiVersion fails", apiVersion: "admission.k8s.io/v2", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"kind":"AdmissionReview","apiVersion":"` + tt.apiVersion + `","request":{"uid":"9e9322a0","kind":{"group":"","version":"v1","kind":"Pod"},"resource":{"group":"","version":"v1","resource":"pods"},"namespace":"foo","operation":"CREATE","object":` + pod + `}}`
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			w := httptest.NewRecorder()
+
+			m := &Mutator{Policy: Policy{Default: Defaults{Containers: defaults}}, Strategy: ComplementToDefault{}}
+			err := m.Mutate(w, r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Mutate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			out := admission_v1.AdmissionReview{}
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if out.APIVersion != tt.apiVersion || out.Kind != "AdmissionReview" {
+				t.Errorf("Mutate() answered with %s %s, want %s AdmissionReview", out.APIVersion, out.Kind, tt.apiVersion)
+			}
+			if out.Response == nil || out.Response.UID != "9e9322a0" || !out.Response.Allowed {
+				t.Fatalf("Mutate() response = %+v", out.Response)
+			}
+			if len(out.Response.Warnings) != 1 {
+				t.Errorf("Mutate() warnings = %v, want one for the defaulted container", out.Response.Warnings)
+			}
+		})
+	}
+}
+
+func Test_createResponse_guaranteed(t *testing.T) {
+	initDefaults := parseTestResourceRequirements("512M", "0.5", "256M", "0.05")
+	d := Defaults{Containers: defaults, InitContainers: &initDefaults, QoS: qosGuaranteed}
+
+	spec := k8s_v1.PodSpec{
+		Containers:     []k8s_v1.Container{{Name: "app", Resources: parseTestResourceRequirements("", "", "", "0.01")}},
+		InitContainers: []k8s_v1.Container{{Name: "migrate"}},
+	}
+	s := podSpec{Path: "/spec", Spec: spec}
+	resp, _, err := createResponse([]podSpec{s}, staticDefaults(d), ComplementToDefault{}, appliedMarker{})
+	if err != nil {
+		t.Fatalf("createResponse() error = %v", err)
+	}
+	if !resp.Allowed {
+		t.Fatalf("createResponse() denied: %v", resp.Result)
+	}
+	doc := testObject(t, s)
+	got := patchedTestResources(t, s.Path, doc, applyTestPatch(t, doc, resp.Patch))
+	for path, want := range map[string]k8s_v1.ResourceRequirements{
+		"/spec/containers/0/resources":     parseTestResourceRequirements(limitMemory, limitCPU, limitMemory, limitCPU),
+		"/spec/initContainers/0/resources": parseTestResourceRequirements("512M", "0.5", "512M", "0.5"),
+	} {
+		if !equalResources(got[path], want) {
+			t.Errorf("createResponse() %s = %v, want %v", path, got[path], want)
+		}
+	}
+
+	// without a cpu limit default the pod can't get Guaranteed
+	d.Containers = parseTestResourceRequirements(limitMemory, "", requestMemory, requestCPU)
+	resp, _, err = createResponse([]podSpec{s}, staticDefaults(d), ComplementToDefault{}, appliedMarker{})
+	if err != nil {
+		t.Fatalf("createResponse() error = %v", err)
+	}
+	if resp.Allowed || !strings.Contains(resp.Result.Message, "Guaranteed") {
+		t.Errorf("createResponse() = %v, want a denial explaining the missing Guaranteed QoS", resp.Result)
+	}
+}